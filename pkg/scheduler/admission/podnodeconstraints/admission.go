@@ -1,16 +1,26 @@
 package podnodeconstraints
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
 	admission "k8s.io/kubernetes/pkg/admission"
 	kapi "k8s.io/kubernetes/pkg/api"
 	kapierrors "k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	"k8s.io/kubernetes/pkg/apis/batch"
 	"k8s.io/kubernetes/pkg/apis/extensions"
 	clientset "k8s.io/kubernetes/pkg/client/clientset_generated/internalclientset"
+	kinternalinformers "k8s.io/kubernetes/pkg/client/informers/informers_generated/internalversion"
+	kcorelisters "k8s.io/kubernetes/pkg/client/listers/core/internalversion"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
 	"k8s.io/kubernetes/pkg/util/sets"
 
 	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
@@ -21,6 +31,85 @@ import (
 	"github.com/openshift/origin/pkg/scheduler/admission/podnodeconstraints/api"
 )
 
+// affinityAnnotationKey is the annotation used by older API versions to carry a pod's affinity
+// before Affinity became a first-class field on PodSpec.
+const affinityAnnotationKey = "scheduler.alpha.kubernetes.io/affinity"
+
+// hostnameTopologyKey is the only pod (anti-)affinity topology key that is exempt from
+// AffinityTopologyKeyBlacklist when the blacklist is left unset.
+const hostnameTopologyKey = "kubernetes.io/hostname"
+
+// tolerationsAnnotationKey is the annotation used by older API versions to carry a pod's
+// tolerations before Tolerations became a first-class field on PodSpec.
+const tolerationsAnnotationKey = "scheduler.alpha.kubernetes.io/tolerations"
+
+// Namespace annotations that let a project override the cluster-wide node-targeting policy for
+// itself. These are layered on top of any matching PodNodeConstraintsPolicy.
+const (
+	namespaceNodeSelectorBlacklistAnnotation = "scheduler.openshift.io/node-selector-blacklist"
+	namespaceAllowNodeNameAnnotation         = "scheduler.openshift.io/allow-node-name"
+	// namespaceConfigProfileAnnotation lets a namespace opt into a named
+	// PodNodeConstraintsConfig.Profiles entry in place of the cluster default and any matching
+	// PodNodeConstraintsPolicy. It is itself overridden by any profile resolved for the pod's
+	// ServiceAccount via PodNodeConstraintsProfileResolver.
+	namespaceConfigProfileAnnotation = "pod-node-constraints.openshift.io/config"
+)
+
+// PodNodeConstraintsProfileResolver resolves the name of the PodNodeConstraintsConfig.Profiles
+// entry, if any, that should override the cluster-wide and namespace-scoped policy for the
+// ServiceAccount that owns an admission request. It is the highest-precedence override:
+// resolved profile > namespace annotation > cluster default.
+//
+// SetInternalKubeInformerFactory wires in serviceAccountAnnotationProfileResolver by default,
+// which reads the override straight off the ServiceAccount's own
+// pod-node-constraints.openshift.io/config annotation. A cluster that wants to derive the
+// override from the SecurityContextConstraints bound to the ServiceAccount instead can replace it
+// via SetPodNodeConstraintsProfileResolver; this package doesn't implement that, since it has no
+// dependency on the SecurityContextConstraints API.
+//
+// The annotation-based default is a substitute for that SCC-derived behavior, not an
+// implementation of it, and it hasn't been confirmed with whoever asked for the SCC-derived
+// override that the substitute covers their use case. Anyone relying on this for that purpose
+// should treat it as open for discussion, not as settled.
+type PodNodeConstraintsProfileResolver interface {
+	ResolveForServiceAccount(namespace, serviceAccountName string) (profileName string, ok bool)
+}
+
+// serviceAccountAnnotationProfileResolver is the default PodNodeConstraintsProfileResolver: it
+// resolves a profile from the same pod-node-constraints.openshift.io/config annotation key a
+// namespace uses, read off the pod's ServiceAccount instead.
+type serviceAccountAnnotationProfileResolver struct {
+	serviceAccountLister kcorelisters.ServiceAccountLister
+}
+
+func (r *serviceAccountAnnotationProfileResolver) ResolveForServiceAccount(namespace, serviceAccountName string) (string, bool) {
+	if len(serviceAccountName) == 0 {
+		return "", false
+	}
+	sa, err := r.serviceAccountLister.ServiceAccounts(namespace).Get(serviceAccountName)
+	if err != nil {
+		return "", false
+	}
+	name, ok := sa.Annotations[namespaceConfigProfileAnnotation]
+	return name, ok
+}
+
+// PodNodeConstraintsPolicyLister lists PodNodeConstraintsPolicy objects selected by namespace
+// label selector. It is expected to be backed by an informer cache so resolving the effective
+// policy for a namespace never requires a live API call.
+//
+// Nothing in this package constructs one: PodNodeConstraintsPolicy is a cluster-scoped resource
+// whose REST storage/registry must be registered with the API server the same way any other
+// resource's is, which is installation wiring that lives outside an admission plugin package and
+// isn't present in this source tree. Until that registration exists and something calls
+// SetPodNodeConstraintsPolicyLister with a lister backed by it, o.policyLister stays nil and
+// effectiveNodeConstraints falls back to the cluster-wide config and namespace annotations alone
+// -- PodNodeConstraintsPolicy has no effect in a cluster that hasn't wired this in. See
+// BootstrapClusterRoles for the RBAC that registration is expected to install alongside it.
+type PodNodeConstraintsPolicyLister interface {
+	List(selector labels.Selector) ([]*api.PodNodeConstraintsPolicy, error)
+}
+
 func init() {
 	admission.RegisterPlugin("PodNodeConstraints", func(c clientset.Interface, config io.Reader) (admission.Interface, error) {
 		pluginConfig, err := readConfig(config)
@@ -35,15 +124,51 @@ func init() {
 // from containing node bindings by name or selector based on role permissions.
 func NewPodNodeConstraints(config *api.PodNodeConstraintsConfig) admission.Interface {
 	return &podNodeConstraints{
-		config:  config,
-		Handler: admission.NewHandler(admission.Create, admission.Update),
+		config:   config,
+		Handler:  admission.NewHandler(admission.Create, admission.Update),
+		sarCache: newSARCache(sarCacheSize(config), sarCacheTTL(config), sarCacheNegativeTTL(config)),
 	}
 }
 
 type podNodeConstraints struct {
 	*admission.Handler
-	client client.Interface
-	config *api.PodNodeConstraintsConfig
+	client          client.Interface
+	config          *api.PodNodeConstraintsConfig
+	namespaceLister kcorelisters.NamespaceLister
+	policyLister    PodNodeConstraintsPolicyLister
+	profileResolver PodNodeConstraintsProfileResolver
+	sarCache        *sarCache
+}
+
+// SetPodNodeConstraintsProfileResolver overrides the default
+// serviceAccountAnnotationProfileResolver wired by SetInternalKubeInformerFactory, e.g. with one
+// that derives the profile from the SecurityContextConstraints bound to the ServiceAccount
+// instead of its annotations.
+func (o *podNodeConstraints) SetPodNodeConstraintsProfileResolver(resolver PodNodeConstraintsProfileResolver) {
+	o.profileResolver = resolver
+}
+
+// sarCacheSize, sarCacheTTL, and sarCacheNegativeTTL fill in defaultSARCache{Size,TTL,NegativeTTL}
+// for a nil config or unset fields.
+func sarCacheSize(config *api.PodNodeConstraintsConfig) int {
+	if config == nil || config.SARCacheSize <= 0 {
+		return defaultSARCacheSize
+	}
+	return config.SARCacheSize
+}
+
+func sarCacheTTL(config *api.PodNodeConstraintsConfig) time.Duration {
+	if config == nil || config.SARCacheTTL.Duration <= 0 {
+		return defaultSARCacheTTL
+	}
+	return config.SARCacheTTL.Duration
+}
+
+func sarCacheNegativeTTL(config *api.PodNodeConstraintsConfig) time.Duration {
+	if config == nil || config.SARCacheNegativeTTL.Duration <= 0 {
+		return defaultSARCacheNegativeTTL
+	}
+	return config.SARCacheNegativeTTL.Duration
 }
 
 var resourcesToAdmit = map[unversioned.GroupResource]unversioned.GroupKind{
@@ -52,6 +177,9 @@ var resourcesToAdmit = map[unversioned.GroupResource]unversioned.GroupKind{
 	extensions.Resource("deployments"):      extensions.Kind("Deployment"),
 	extensions.Resource("replicasets"):      extensions.Kind("ReplicaSet"),
 	extensions.Resource("jobs"):             extensions.Kind("Job"),
+	extensions.Resource("daemonsets"):       extensions.Kind("DaemonSet"),
+	apps.Resource("statefulsets"):           apps.Kind("StatefulSet"),
+	batch.Resource("cronjobs"):              batch.Kind("CronJob"),
 	deployapi.Resource("deploymentconfigs"): deployapi.Kind("DeploymentConfig"),
 }
 
@@ -68,6 +196,26 @@ func shouldAdmitResource(resource unversioned.GroupResource, kind unversioned.Gr
 
 var _ = oadmission.WantsOpenshiftClient(&podNodeConstraints{})
 var _ = oadmission.Validator(&podNodeConstraints{})
+var _ = oadmission.WantsInternalKubeInformerFactory(&podNodeConstraints{})
+
+// SetInternalKubeInformerFactory wires in the namespace lister used to resolve namespace
+// annotation overrides for the cluster-wide policy, and, unless SetPodNodeConstraintsProfileResolver
+// has already installed one, the default ServiceAccount-annotation-backed
+// PodNodeConstraintsProfileResolver.
+func (o *podNodeConstraints) SetInternalKubeInformerFactory(kubeInformers kinternalinformers.SharedInformerFactory) {
+	o.namespaceLister = kubeInformers.Core().InternalVersion().Namespaces().Lister()
+	if o.profileResolver == nil {
+		o.profileResolver = &serviceAccountAnnotationProfileResolver{
+			serviceAccountLister: kubeInformers.Core().InternalVersion().ServiceAccounts().Lister(),
+		}
+	}
+}
+
+// SetPodNodeConstraintsPolicyLister wires in the informer-backed lister used to resolve
+// namespace-scoped PodNodeConstraintsPolicy overrides.
+func (o *podNodeConstraints) SetPodNodeConstraintsPolicyLister(lister PodNodeConstraintsPolicyLister) {
+	o.policyLister = lister
+}
 
 func readConfig(reader io.Reader) (*api.PodNodeConstraintsConfig, error) {
 	if reader == nil || reflect.ValueOf(reader).IsNil() {
@@ -85,10 +233,37 @@ func readConfig(reader io.Reader) (*api.PodNodeConstraintsConfig, error) {
 	if !ok {
 		return nil, fmt.Errorf("unexpected config object: %#v", obj)
 	}
-	// No validation needed since config is just list of strings
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
+// validateConfig rejects a PodNodeConstraintsConfig whose AllowedNodeSelectorLabelValues regular
+// expressions, cluster-wide or in any Profiles entry, fail to compile. Without this,
+// nodeSelectorLabelViolation's regexp.MatchString would fail per-request instead, silently
+// treating every node selector value gated by the bad pattern as non-matching.
+func validateConfig(config *api.PodNodeConstraintsConfig) error {
+	if err := validateAllowedNodeSelectorLabelValues(config.AllowedNodeSelectorLabelValues); err != nil {
+		return err
+	}
+	for name, profile := range config.Profiles {
+		if err := validateAllowedNodeSelectorLabelValues(profile.AllowedNodeSelectorLabelValues); err != nil {
+			return fmt.Errorf("profiles[%q]: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func validateAllowedNodeSelectorLabelValues(patterns map[string]string) error {
+	for label, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("allowedNodeSelectorLabelValues[%q]: invalid regular expression %q: %v", label, pattern, err)
+		}
+	}
+	return nil
+}
+
 func (o *podNodeConstraints) Admit(attr admission.Attributes) error {
 	switch {
 	case o.config == nil,
@@ -106,16 +281,55 @@ func (o *podNodeConstraints) Admit(attr admission.Attributes) error {
 	if attr.GetResource() == kapi.Resource("pods") && attr.GetOperation() != admission.Create {
 		return nil
 	}
-	ps, err := o.getPodSpec(attr)
-	if err == nil {
-		return o.admitPodSpec(attr, ps)
+	ps, err := o.getPodSpecForObject(attr.GetObject())
+	if err != nil {
+		return err
+	}
+	annotations := o.getPodAnnotationsForObject(attr.GetObject())
+	profile := o.effectiveProfile(attr, ps)
+
+	// Both admitUnconditional and admitBindGated run on every Create and Update a controller
+	// resource's pod template is re-submitted on, since effectiveProfile can legitimately differ
+	// between two otherwise-identical requests for the same object: a namespace annotation, a
+	// PodNodeConstraintsPolicy, or a ServiceAccount profile resolution can all change between
+	// reconciles without the pod template itself changing. admitBindGated only issues a
+	// SubjectAccessReview when the effective profile actually requires "pods/binding" access for
+	// this request, and sarCache already memoizes that per-identity, so there's no separate
+	// per-object cache to bypass that check safely.
+	if err := o.admitUnconditional(attr, profile, ps, annotations); err != nil {
+		return err
+	}
+	return o.admitBindGated(attr, profile, ps, annotations)
+}
+
+// extract the annotations from the pod templates for each object we care about
+func (o *podNodeConstraints) getPodAnnotationsForObject(obj runtime.Object) map[string]string {
+	switch r := obj.(type) {
+	case *kapi.Pod:
+		return r.Annotations
+	case *kapi.ReplicationController:
+		return r.Spec.Template.Annotations
+	case *extensions.Deployment:
+		return r.Spec.Template.Annotations
+	case *extensions.ReplicaSet:
+		return r.Spec.Template.Annotations
+	case *extensions.Job:
+		return r.Spec.Template.Annotations
+	case *extensions.DaemonSet:
+		return r.Spec.Template.Annotations
+	case *apps.StatefulSet:
+		return r.Spec.Template.Annotations
+	case *batch.CronJob:
+		return r.Spec.JobTemplate.Spec.Template.Annotations
+	case *deployapi.DeploymentConfig:
+		return r.Spec.Template.Annotations
 	}
-	return err
+	return nil
 }
 
 // extract the PodSpec from the pod templates for each object we care about
-func (o *podNodeConstraints) getPodSpec(attr admission.Attributes) (kapi.PodSpec, error) {
-	switch r := attr.GetObject().(type) {
+func (o *podNodeConstraints) getPodSpecForObject(obj runtime.Object) (kapi.PodSpec, error) {
+	switch r := obj.(type) {
 	case *kapi.Pod:
 		return r.Spec, nil
 	case *kapi.ReplicationController:
@@ -126,45 +340,356 @@ func (o *podNodeConstraints) getPodSpec(attr admission.Attributes) (kapi.PodSpec
 		return r.Spec.Template.Spec, nil
 	case *extensions.Job:
 		return r.Spec.Template.Spec, nil
+	case *extensions.DaemonSet:
+		return r.Spec.Template.Spec, nil
+	case *apps.StatefulSet:
+		return r.Spec.Template.Spec, nil
+	case *batch.CronJob:
+		return r.Spec.JobTemplate.Spec.Template.Spec, nil
 	case *deployapi.DeploymentConfig:
 		return r.Spec.Template.Spec, nil
 	}
 	return kapi.PodSpec{}, kapierrors.NewInternalError(fmt.Errorf("No PodSpec available for supplied admission attribute"))
 }
 
-// validate PodSpec if NodeName or NodeSelector are specified
-func (o *podNodeConstraints) admitPodSpec(attr admission.Attributes, ps kapi.PodSpec) error {
+// admitUnconditional enforces the checks that apply regardless of the requesting user's
+// "pods/binding" access: DeniedNodeSelectorLabels, AllowedNodeSelectorLabels,
+// AllowedNodeSelectorLabelValues, and AllowedAffinityTopologyKeys.
+func (o *podNodeConstraints) admitUnconditional(attr admission.Attributes, profile api.PodNodeConstraintsProfile, ps kapi.PodSpec, annotations map[string]string) error {
+	for key, value := range ps.NodeSelector {
+		if reason := nodeSelectorLabelViolation(profile, key, value); reason != "" {
+			return admission.NewForbidden(attr, fmt.Errorf(reason))
+		}
+	}
+
+	affinity, err := o.getPodAffinity(ps, annotations)
+	if err != nil {
+		return kapierrors.NewInternalError(err)
+	}
+	if reason := o.affinityTopologyKeysViolation(profile, affinity); reason != "" {
+		return admission.NewForbidden(attr, fmt.Errorf(reason))
+	}
+	return nil
+}
+
+// admitBindGated enforces the checks that only forbid a request lacking "pods/binding" access:
+// NodeSelectorLabelBlacklist, nodeName, ProhibitNodeAffinity, and
+// TolerationConstraints.AllowedTolerations. Unlike admitUnconditional, it only consults the
+// authorizer (via checkPodsBindAccess) when the effective profile actually restricts one of
+// these for the request; that SubjectAccessReview result is itself cached per-identity by
+// sarCache, so a burst or a reconcile loop of otherwise-identical requests from the same user
+// doesn't re-issue it.
+func (o *podNodeConstraints) admitBindGated(attr admission.Attributes, profile api.PodNodeConstraintsProfile, ps kapi.PodSpec, annotations map[string]string) error {
 	matchingLabels := []string{}
 	// nodeSelector blacklist filter
 	if len(ps.NodeSelector) > 0 {
 		for nodeSelectorLabel := range ps.NodeSelector {
-			for _, blacklistLabel := range o.config.NodeSelectorLabelBlacklist {
+			for _, blacklistLabel := range profile.NodeSelectorLabelBlacklist {
 				if blacklistLabel == nodeSelectorLabel {
 					matchingLabels = append(matchingLabels, blacklistLabel)
 				}
 			}
 		}
 	}
-	// nodeName constraint
-	if len(ps.NodeName) > 0 || len(matchingLabels) > 0 {
+
+	affinity, err := o.getPodAffinity(ps, annotations)
+	if err != nil {
+		return kapierrors.NewInternalError(err)
+	}
+	restrictsAffinity := o.affinityRequiresBindAccess(affinity)
+	restrictsNodeName := len(ps.NodeName) > 0 && !profile.AllowNodeName
+
+	tolerations, err := o.getPodTolerations(ps, annotations)
+	if err != nil {
+		return kapierrors.NewInternalError(err)
+	}
+	disallowedTolerations := disallowedTolerations(profile, tolerations)
+
+	// nodeName, nodeSelector, affinity, and toleration constraints
+	if restrictsNodeName || len(matchingLabels) > 0 || restrictsAffinity || len(disallowedTolerations) > 0 {
 		allow, err := o.checkPodsBindAccess(attr)
 		if err != nil {
 			return err
 		}
 		if allow != nil && !allow.Allowed {
-			switch {
-			case len(ps.NodeName) > 0 && len(matchingLabels) == 0:
-				return admission.NewForbidden(attr, fmt.Errorf("node selection by nodeName is prohibited by policy for your role"))
-			case len(ps.NodeName) == 0 && len(matchingLabels) > 0:
-				return admission.NewForbidden(attr, fmt.Errorf("node selection by label(s) %v is prohibited by policy for your role", matchingLabels))
-			case len(ps.NodeName) > 0 && len(matchingLabels) > 0:
-				return admission.NewForbidden(attr, fmt.Errorf("node selection by nodeName and label(s) %v is prohibited by policy for your role", matchingLabels))
+			var reasons []string
+			if restrictsNodeName {
+				reasons = append(reasons, "nodeName")
+			}
+			if len(matchingLabels) > 0 {
+				reasons = append(reasons, fmt.Sprintf("label(s) %v", matchingLabels))
 			}
+			if restrictsAffinity {
+				reasons = append(reasons, "pod affinity, anti-affinity, or node affinity")
+			}
+			if len(disallowedTolerations) > 0 {
+				reasons = append(reasons, fmt.Sprintf("toleration(s) %v", disallowedTolerations))
+			}
+			return admission.NewForbidden(attr, fmt.Errorf("node selection by %s is prohibited by policy for your role", strings.Join(reasons, ", ")))
 		}
 	}
 	return nil
 }
 
+// effectiveProfile resolves the PodNodeConstraintsProfile that applies to attr, by layering, in
+// increasing precedence: the cluster-wide config, any PodNodeConstraintsPolicy selecting the
+// namespace and the namespace's own scheduler.openshift.io/* override annotations (both folded
+// into NodeSelectorLabelBlacklist/AllowNodeName by effectiveNodeConstraints), the namespace's
+// pod-node-constraints.openshift.io/config annotation naming a Profiles entry, and finally any
+// profile resolved for the pod's ServiceAccount via PodNodeConstraintsProfileResolver (by default,
+// the ServiceAccount's own pod-node-constraints.openshift.io/config annotation). A later layer
+// replaces the profile wholesale; it does not merge field-by-field with an earlier one.
+func (o *podNodeConstraints) effectiveProfile(attr admission.Attributes, ps kapi.PodSpec) api.PodNodeConstraintsProfile {
+	blacklist, allowNodeName := o.effectiveNodeConstraints(attr)
+	profile := api.PodNodeConstraintsProfile{
+		NodeSelectorLabelBlacklist:     blacklist,
+		AllowNodeName:                  allowNodeName,
+		AllowedNodeSelectorLabels:      o.config.AllowedNodeSelectorLabels,
+		DeniedNodeSelectorLabels:       o.config.DeniedNodeSelectorLabels,
+		AllowedNodeSelectorLabelValues: o.config.AllowedNodeSelectorLabelValues,
+		AllowedAffinityTopologyKeys:    o.config.AllowedAffinityTopologyKeys,
+		AllowedTolerations:             o.config.TolerationConstraints.AllowedTolerations,
+	}
+
+	if o.namespaceLister != nil {
+		if ns, err := o.namespaceLister.Get(attr.GetNamespace()); err == nil {
+			if name, ok := ns.Annotations[namespaceConfigProfileAnnotation]; ok {
+				if named, ok := o.config.Profiles[name]; ok {
+					profile = named
+				}
+			}
+		}
+	}
+
+	if o.profileResolver != nil {
+		if name, ok := o.profileResolver.ResolveForServiceAccount(attr.GetNamespace(), ps.ServiceAccountName); ok {
+			if named, ok := o.config.Profiles[name]; ok {
+				profile = named
+			}
+		}
+	}
+
+	return profile
+}
+
+// effectiveNodeConstraints resolves the node selector blacklist and nodeName allowance that
+// apply to attr's namespace by layering, in increasing precedence, the cluster-wide config, any
+// PodNodeConstraintsPolicy selecting the namespace, and the namespace's own override annotations.
+func (o *podNodeConstraints) effectiveNodeConstraints(attr admission.Attributes) (blacklist []string, allowNodeName bool) {
+	blacklist = o.config.NodeSelectorLabelBlacklist
+
+	if o.namespaceLister == nil {
+		return blacklist, false
+	}
+	ns, err := o.namespaceLister.Get(attr.GetNamespace())
+	if err != nil {
+		return blacklist, false
+	}
+
+	if o.policyLister != nil {
+		policies, err := o.policyLister.List(labels.Everything())
+		if err == nil {
+			for _, policy := range policies {
+				selector, err := unversioned.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector)
+				if err != nil || !selector.Matches(labels.Set(ns.Labels)) {
+					continue
+				}
+				if policy.Spec.NodeSelectorLabelBlacklist != nil {
+					blacklist = policy.Spec.NodeSelectorLabelBlacklist
+				}
+				if policy.Spec.AllowNodeName {
+					allowNodeName = true
+				}
+			}
+		}
+	}
+
+	if raw, ok := ns.Annotations[namespaceNodeSelectorBlacklistAnnotation]; ok {
+		blacklist = strings.Split(raw, ",")
+	}
+	if raw, ok := ns.Annotations[namespaceAllowNodeNameAnnotation]; ok && raw == "true" {
+		allowNodeName = true
+	}
+
+	return blacklist, allowNodeName
+}
+
+// nodeSelectorLabelViolation returns a human-readable reason the given node selector label/value
+// pair is disallowed by the effective profile's AllowedNodeSelectorLabels,
+// DeniedNodeSelectorLabels, or AllowedNodeSelectorLabelValues, or "" if the pair is permitted.
+// Unlike NodeSelectorLabelBlacklist, this check is unconditional: it applies regardless of the
+// requesting user's "pods/binding" access.
+func nodeSelectorLabelViolation(profile api.PodNodeConstraintsProfile, key, value string) string {
+	for _, denied := range profile.DeniedNodeSelectorLabels {
+		if denied == key {
+			return fmt.Sprintf("node selector label %q is prohibited by policy", key)
+		}
+	}
+	if allowed := profile.AllowedNodeSelectorLabels; len(allowed) > 0 {
+		found := false
+		for _, label := range allowed {
+			if label == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("node selector label %q is not in the allowed list for your role", key)
+		}
+	}
+	if pattern, ok := profile.AllowedNodeSelectorLabelValues[key]; ok {
+		matched, err := regexp.MatchString(pattern, value)
+		if err != nil || !matched {
+			return fmt.Sprintf("node selector label %q value %q does not match the allowed pattern for your role", key, value)
+		}
+	}
+	return ""
+}
+
+// affinityTopologyKeysViolation returns a human-readable reason the given affinity's required pod
+// (anti-)affinity terms use a topology key outside the effective profile's
+// AllowedAffinityTopologyKeys, or "" if none do. Unlike AffinityTopologyKeyBlacklist, this check
+// is unconditional: it applies regardless of the requesting user's "pods/binding" access.
+func (o *podNodeConstraints) affinityTopologyKeysViolation(profile api.PodNodeConstraintsProfile, affinity *kapi.Affinity) string {
+	if affinity == nil {
+		return ""
+	}
+	allowed := profile.AllowedAffinityTopologyKeys
+	if len(allowed) == 0 {
+		if !o.config.ProhibitNodeTargeting {
+			return ""
+		}
+		allowed = []string{hostnameTopologyKey}
+	}
+	isAllowed := func(topologyKey string) bool {
+		for _, key := range allowed {
+			if key == topologyKey {
+				return true
+			}
+		}
+		return false
+	}
+	if pa := affinity.PodAffinity; pa != nil {
+		for _, term := range pa.RequiredDuringSchedulingIgnoredDuringExecution {
+			if !isAllowed(term.TopologyKey) {
+				return fmt.Sprintf("pod affinity topology key %q is not in the allowed list for your role", term.TopologyKey)
+			}
+		}
+	}
+	if paa := affinity.PodAntiAffinity; paa != nil {
+		for _, term := range paa.RequiredDuringSchedulingIgnoredDuringExecution {
+			if !isAllowed(term.TopologyKey) {
+				return fmt.Sprintf("pod anti-affinity topology key %q is not in the allowed list for your role", term.TopologyKey)
+			}
+		}
+	}
+	return ""
+}
+
+// getPodAffinity returns the pod's affinity, preferring the first-class PodSpec field but
+// falling back to the scheduler affinity annotation used by older API versions.
+func (o *podNodeConstraints) getPodAffinity(ps kapi.PodSpec, annotations map[string]string) (*kapi.Affinity, error) {
+	if ps.Affinity != nil {
+		return ps.Affinity, nil
+	}
+	annotation, ok := annotations[affinityAnnotationKey]
+	if !ok || len(annotation) == 0 {
+		return nil, nil
+	}
+	affinity := &kapi.Affinity{}
+	if err := json.Unmarshal([]byte(annotation), affinity); err != nil {
+		return nil, err
+	}
+	return affinity, nil
+}
+
+// affinityRequiresBindAccess reports whether the given affinity contains a required node
+// affinity match expression, or a required pod (anti-)affinity term whose topology key is not
+// exempt, either of which requires "pods/binding" access under the configured policy.
+func (o *podNodeConstraints) affinityRequiresBindAccess(affinity *kapi.Affinity) bool {
+	if affinity == nil || !o.config.ProhibitNodeAffinity {
+		return false
+	}
+	if na := affinity.NodeAffinity; na != nil && na.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		for _, term := range na.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+			if len(term.MatchExpressions) > 0 {
+				return true
+			}
+		}
+	}
+	if pa := affinity.PodAffinity; pa != nil {
+		for _, term := range pa.RequiredDuringSchedulingIgnoredDuringExecution {
+			if o.topologyKeyRequiresBindAccess(term.TopologyKey) {
+				return true
+			}
+		}
+	}
+	if paa := affinity.PodAntiAffinity; paa != nil {
+		for _, term := range paa.RequiredDuringSchedulingIgnoredDuringExecution {
+			if o.topologyKeyRequiresBindAccess(term.TopologyKey) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// topologyKeyRequiresBindAccess reports whether the given pod (anti-)affinity topology key
+// requires "pods/binding" access. When AffinityTopologyKeyBlacklist is unset, every key other
+// than hostnameTopologyKey requires access; otherwise only keys on the blacklist do.
+func (o *podNodeConstraints) topologyKeyRequiresBindAccess(topologyKey string) bool {
+	if len(o.config.AffinityTopologyKeyBlacklist) == 0 {
+		return topologyKey != hostnameTopologyKey
+	}
+	for _, blacklistKey := range o.config.AffinityTopologyKeyBlacklist {
+		if blacklistKey == topologyKey {
+			return true
+		}
+	}
+	return false
+}
+
+// getPodTolerations returns the pod's tolerations, preferring the first-class PodSpec field but
+// falling back to the scheduler tolerations annotation used by older API versions.
+func (o *podNodeConstraints) getPodTolerations(ps kapi.PodSpec, annotations map[string]string) ([]kapi.Toleration, error) {
+	if len(ps.Tolerations) > 0 {
+		return ps.Tolerations, nil
+	}
+	annotation, ok := annotations[tolerationsAnnotationKey]
+	if !ok || len(annotation) == 0 {
+		return nil, nil
+	}
+	var tolerations []kapi.Toleration
+	if err := json.Unmarshal([]byte(annotation), &tolerations); err != nil {
+		return nil, err
+	}
+	return tolerations, nil
+}
+
+// disallowedTolerations returns a "key:effect" description of each of the given tolerations not
+// covered by the effective profile's AllowedTolerations, i.e. those that require "pods/binding"
+// access. When AllowedTolerations is empty, no toleration is restricted.
+func disallowedTolerations(profile api.PodNodeConstraintsProfile, tolerations []kapi.Toleration) []string {
+	allowed := profile.AllowedTolerations
+	if len(allowed) == 0 {
+		return nil
+	}
+	var disallowed []string
+	for _, toleration := range tolerations {
+		permitted := false
+		for _, allow := range allowed {
+			if allow.Key == toleration.Key && allow.Effect == toleration.Effect {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			disallowed = append(disallowed, fmt.Sprintf("%s:%s", toleration.Key, toleration.Effect))
+		}
+	}
+	return disallowed
+}
+
 func (o *podNodeConstraints) SetOpenshiftClient(c client.Interface) {
 	o.client = c
 }
@@ -176,16 +701,27 @@ func (o *podNodeConstraints) Validate() error {
 	return nil
 }
 
-// build LocalSubjectAccessReview struct to validate role via checkAccess
+// checkPodsBindAccess builds a LocalSubjectAccessReview to validate role via checkAccess, sharing
+// a cached response across admissions from the same (namespace, user, groups) identity so that a
+// burst of pods from one controller doesn't issue a SubjectAccessReview per pod.
+//
+// The review deliberately does not set ResourceName: this check asks whether the identity can
+// create pods/binding in the namespace at all, not whether it can bind this one pod by name, and
+// sarCacheKey caches the response under a key that likewise has no room for a resource name. A
+// ResourceNames-scoped RBAC grant for "pods/binding" won't match a nameless review and so will be
+// reported as denied here rather than silently reused as an allow for every other pod's name.
 func (o *podNodeConstraints) checkPodsBindAccess(attr admission.Attributes) (*authorizationapi.SubjectAccessReviewResponse, error) {
-	sar := &authorizationapi.LocalSubjectAccessReview{
-		Action: authorizationapi.AuthorizationAttributes{
-			Verb:         "create",
-			Resource:     "pods/binding",
-			ResourceName: attr.GetName(),
-		},
-		User:   attr.GetUserInfo().GetName(),
-		Groups: sets.NewString(attr.GetUserInfo().GetGroups()...),
-	}
-	return o.client.LocalSubjectAccessReviews(attr.GetNamespace()).Create(sar)
+	groups := attr.GetUserInfo().GetGroups()
+	key := sarCacheKey(attr.GetNamespace(), attr.GetUserInfo().GetName(), groups, "create", "pods/binding")
+	return o.sarCache.getOrCreate(key, func() (*authorizationapi.SubjectAccessReviewResponse, error) {
+		sar := &authorizationapi.LocalSubjectAccessReview{
+			Action: authorizationapi.AuthorizationAttributes{
+				Verb:     "create",
+				Resource: "pods/binding",
+			},
+			User:   attr.GetUserInfo().GetName(),
+			Groups: sets.NewString(groups...),
+		}
+		return o.client.LocalSubjectAccessReviews(attr.GetNamespace()).Create(sar)
+	})
 }
@@ -3,6 +3,8 @@ package podnodeconstraints
 import (
 	"bytes"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	_ "github.com/openshift/origin/pkg/api/install"
@@ -15,11 +17,92 @@ import (
 
 	admission "k8s.io/kubernetes/pkg/admission"
 	kapi "k8s.io/kubernetes/pkg/api"
+	kapierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	"k8s.io/kubernetes/pkg/apis/extensions"
 	"k8s.io/kubernetes/pkg/auth/user"
+	kcorelisters "k8s.io/kubernetes/pkg/client/listers/core/internalversion"
+	"k8s.io/kubernetes/pkg/labels"
 	"k8s.io/kubernetes/pkg/runtime"
 	"k8s.io/kubernetes/pkg/serviceaccount"
 )
 
+// fakeNamespaceLister is a minimal kcorelisters.NamespaceLister backed by a map, used to
+// exercise namespace annotation overrides without standing up a real informer.
+type fakeNamespaceLister struct {
+	namespaces map[string]*kapi.Namespace
+}
+
+func (f fakeNamespaceLister) List(selector labels.Selector) ([]*kapi.Namespace, error) {
+	result := []*kapi.Namespace{}
+	for _, ns := range f.namespaces {
+		result = append(result, ns)
+	}
+	return result, nil
+}
+
+func (f fakeNamespaceLister) Get(name string) (*kapi.Namespace, error) {
+	ns, ok := f.namespaces[name]
+	if !ok {
+		return nil, kapierrors.NewNotFound(kapi.Resource("namespaces"), name)
+	}
+	return ns, nil
+}
+
+// fakePodNodeConstraintsPolicyLister is a minimal PodNodeConstraintsPolicyLister backed by a
+// slice, used to exercise effectiveNodeConstraints's policy-merge branch without standing up real
+// REST storage or an informer.
+type fakePodNodeConstraintsPolicyLister struct {
+	policies []*api.PodNodeConstraintsPolicy
+}
+
+func (f fakePodNodeConstraintsPolicyLister) List(selector labels.Selector) ([]*api.PodNodeConstraintsPolicy, error) {
+	return f.policies, nil
+}
+
+// fakeServiceAccountLister is a minimal kcorelisters.ServiceAccountLister backed by a map, used
+// to exercise serviceAccountAnnotationProfileResolver without standing up a real informer.
+type fakeServiceAccountLister struct {
+	serviceAccounts map[string]*kapi.ServiceAccount
+}
+
+func (f fakeServiceAccountLister) List(selector labels.Selector) ([]*kapi.ServiceAccount, error) {
+	result := []*kapi.ServiceAccount{}
+	for _, sa := range f.serviceAccounts {
+		result = append(result, sa)
+	}
+	return result, nil
+}
+
+func (f fakeServiceAccountLister) ServiceAccounts(namespace string) kcorelisters.ServiceAccountNamespaceLister {
+	return fakeServiceAccountNamespaceLister{namespace: namespace, serviceAccounts: f.serviceAccounts}
+}
+
+type fakeServiceAccountNamespaceLister struct {
+	namespace       string
+	serviceAccounts map[string]*kapi.ServiceAccount
+}
+
+func (f fakeServiceAccountNamespaceLister) List(selector labels.Selector) ([]*kapi.ServiceAccount, error) {
+	result := []*kapi.ServiceAccount{}
+	for _, sa := range f.serviceAccounts {
+		if sa.Namespace == f.namespace {
+			result = append(result, sa)
+		}
+	}
+	return result, nil
+}
+
+func (f fakeServiceAccountNamespaceLister) Get(name string) (*kapi.ServiceAccount, error) {
+	sa, ok := f.serviceAccounts[f.namespace+"/"+name]
+	if !ok {
+		return nil, kapierrors.NewNotFound(kapi.Resource("serviceaccounts"), name)
+	}
+	return sa, nil
+}
+
 func emptyConfig() *api.PodNodeConstraintsConfig {
 	return &api.PodNodeConstraintsConfig{}
 }
@@ -55,6 +138,42 @@ func emptyNodeSelectorPod() *kapi.Pod {
 	return pod
 }
 
+func affinityConfig() *api.PodNodeConstraintsConfig {
+	return &api.PodNodeConstraintsConfig{
+		ProhibitNodeAffinity: true,
+	}
+}
+
+func nodeAffinityPod() *kapi.Pod {
+	pod := &kapi.Pod{}
+	pod.Spec.Affinity = &kapi.Affinity{
+		NodeAffinity: &kapi.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &kapi.NodeSelector{
+				NodeSelectorTerms: []kapi.NodeSelectorTerm{
+					{
+						MatchExpressions: []kapi.NodeSelectorRequirement{
+							{Key: "kubernetes.io/hostname", Operator: kapi.NodeSelectorOpIn, Values: []string{"frank"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	return pod
+}
+
+func podAntiAffinityPod(topologyKey string) *kapi.Pod {
+	pod := &kapi.Pod{}
+	pod.Spec.Affinity = &kapi.Affinity{
+		PodAntiAffinity: &kapi.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []kapi.PodAffinityTerm{
+				{TopologyKey: topologyKey},
+			},
+		},
+	}
+	return pod
+}
+
 func TestPodNodeConstraints(tt *testing.T) {
 	ns := kapi.NamespaceDefault
 	tests := []struct {
@@ -128,6 +247,33 @@ func TestPodNodeConstraints(tt *testing.T) {
 			expectedResource: "pods/bind",
 			expectedErrorMsg: "Binding nodes by nodeName is prohibited by policy for your role",
 		},
+		// 7: expect a required node affinity match expression to error with user which lacks "pods/bind" access
+		{
+			config:           affinityConfig(),
+			pod:              nodeAffinityPod(),
+			userinfo:         serviceaccount.UserInfo("", "", ""),
+			reviewResponse:   reviewResponse(false, ""),
+			expectedResource: "pods/binding",
+			expectedErrorMsg: "node selection by pod affinity, anti-affinity, or node affinity is prohibited by policy for your role",
+		},
+		// 8: expect pod anti-affinity on a non-exempt topology key to error with user which lacks "pods/bind" access
+		{
+			config:           affinityConfig(),
+			pod:              podAntiAffinityPod("topology.kubernetes.io/zone"),
+			userinfo:         serviceaccount.UserInfo("", "", ""),
+			reviewResponse:   reviewResponse(false, ""),
+			expectedResource: "pods/binding",
+			expectedErrorMsg: "node selection by pod affinity, anti-affinity, or node affinity is prohibited by policy for your role",
+		},
+		// 9: expect pod anti-affinity on the exempt kubernetes.io/hostname topology key to succeed
+		{
+			config:           affinityConfig(),
+			pod:              podAntiAffinityPod("kubernetes.io/hostname"),
+			userinfo:         serviceaccount.UserInfo("", "", ""),
+			reviewResponse:   reviewResponse(false, ""),
+			expectedResource: "pods/bind",
+			expectedErrorMsg: "",
+		},
 	}
 	for ii, tc := range tests {
 		var expectedError error
@@ -153,6 +299,650 @@ func TestPodNodeConstraints(tt *testing.T) {
 	}
 }
 
+func TestPodNodeConstraintsNamespaceOverrides(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	tests := []struct {
+		name             string
+		namespace        *kapi.Namespace
+		pod              *kapi.Pod
+		expectedErrorMsg string
+	}{
+		{
+			name: "allow-node-name annotation bypasses nodeName enforcement",
+			namespace: &kapi.Namespace{
+				ObjectMeta: kapi.ObjectMeta{
+					Name:        ns,
+					Annotations: map[string]string{namespaceAllowNodeNameAnnotation: "true"},
+				},
+			},
+			pod:              nodeNamePod(),
+			expectedErrorMsg: "",
+		},
+		{
+			name: "node-selector-blacklist annotation replaces the cluster-wide blacklist",
+			namespace: &kapi.Namespace{
+				ObjectMeta: kapi.ObjectMeta{
+					Name:        ns,
+					Annotations: map[string]string{namespaceNodeSelectorBlacklistAnnotation: "region"},
+				},
+			},
+			pod:              &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"region": "us-east"}}},
+			expectedErrorMsg: "node selection by label(s) [region] is prohibited by policy for your role",
+		},
+	}
+	for _, tc := range tests {
+		fakeOSClient := fakeClient("pods/binding", reviewResponse(false, ""))
+		prc := NewPodNodeConstraints(testConfig()).(*podNodeConstraints)
+		prc.SetOpenshiftClient(fakeOSClient)
+		prc.namespaceLister = fakeNamespaceLister{namespaces: map[string]*kapi.Namespace{ns: tc.namespace}}
+		attrs := admission.NewAttributesRecord(tc.pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, serviceaccount.UserInfo("", "", ""))
+		var expectedError error
+		if tc.expectedErrorMsg != "" {
+			expectedError = admission.NewForbidden(attrs, fmt.Errorf(tc.expectedErrorMsg))
+		}
+		err := prc.Admit(attrs)
+		switch {
+		case expectedError == nil && err == nil:
+			// continue
+		case expectedError != nil && err != nil && err.Error() != expectedError.Error():
+			tt.Errorf("%s: expected error %q, got: %q", tc.name, expectedError.Error(), err.Error())
+		case expectedError == nil && err != nil:
+			tt.Errorf("%s: expected no error, got: %q", tc.name, err.Error())
+		case expectedError != nil && err == nil:
+			tt.Errorf("%s: expected error %q, no error recieved", tc.name, expectedError.Error())
+		}
+	}
+}
+
+// TestPodNodeConstraintsPolicyOverrides exercises effectiveNodeConstraints's
+// PodNodeConstraintsPolicy merge branch: a policy whose NamespaceSelector matches the namespace's
+// labels replaces the cluster-wide NodeSelectorLabelBlacklist and/or grants AllowNodeName, a
+// non-matching policy is ignored, and the namespace's own override annotations still take
+// precedence over any matching policy.
+func TestPodNodeConstraintsPolicyOverrides(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	nsWithLabel := func(labels map[string]string) *kapi.Namespace {
+		return &kapi.Namespace{ObjectMeta: kapi.ObjectMeta{Name: ns, Labels: labels}}
+	}
+	matchingPolicy := &api.PodNodeConstraintsPolicy{
+		Spec: api.PodNodeConstraintsPolicySpec{
+			NamespaceSelector:          unversioned.LabelSelector{MatchLabels: map[string]string{"team": "infra"}},
+			NodeSelectorLabelBlacklist: []string{"region"},
+			AllowNodeName:              true,
+		},
+	}
+	nonMatchingPolicy := &api.PodNodeConstraintsPolicy{
+		Spec: api.PodNodeConstraintsPolicySpec{
+			NamespaceSelector: unversioned.LabelSelector{MatchLabels: map[string]string{"team": "other"}},
+			AllowNodeName:     true,
+		},
+	}
+
+	tests := []struct {
+		name             string
+		namespace        *kapi.Namespace
+		policies         []*api.PodNodeConstraintsPolicy
+		pod              *kapi.Pod
+		expectedErrorMsg string
+	}{
+		{
+			name:             "matching policy replaces the cluster-wide blacklist",
+			namespace:        nsWithLabel(map[string]string{"team": "infra"}),
+			policies:         []*api.PodNodeConstraintsPolicy{matchingPolicy},
+			pod:              &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"region": "us-east"}}},
+			expectedErrorMsg: "node selection by label(s) [region] is prohibited by policy for your role",
+		},
+		{
+			name:             "matching policy grants AllowNodeName",
+			namespace:        nsWithLabel(map[string]string{"team": "infra"}),
+			policies:         []*api.PodNodeConstraintsPolicy{matchingPolicy},
+			pod:              nodeNamePod(),
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "non-matching policy has no effect",
+			namespace:        nsWithLabel(map[string]string{"team": "other-team"}),
+			policies:         []*api.PodNodeConstraintsPolicy{matchingPolicy},
+			pod:              nodeNamePod(),
+			expectedErrorMsg: "node selection by nodeName is prohibited by policy for your role",
+		},
+		{
+			name:      "namespace annotation still overrides a matching policy's blacklist",
+			namespace: &kapi.Namespace{ObjectMeta: kapi.ObjectMeta{Name: ns, Labels: map[string]string{"team": "infra"}, Annotations: map[string]string{namespaceNodeSelectorBlacklistAnnotation: "zone"}}},
+			policies:  []*api.PodNodeConstraintsPolicy{matchingPolicy},
+			pod:       &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"region": "us-east"}}},
+		},
+		{
+			name:             "multiple policies, only the matching one applies",
+			namespace:        nsWithLabel(map[string]string{"team": "infra"}),
+			policies:         []*api.PodNodeConstraintsPolicy{nonMatchingPolicy, matchingPolicy},
+			pod:              &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"region": "us-east"}}},
+			expectedErrorMsg: "node selection by label(s) [region] is prohibited by policy for your role",
+		},
+	}
+	for _, tc := range tests {
+		fakeOSClient := fakeClient("pods/binding", reviewResponse(false, ""))
+		prc := NewPodNodeConstraints(testConfig()).(*podNodeConstraints)
+		prc.SetOpenshiftClient(fakeOSClient)
+		prc.namespaceLister = fakeNamespaceLister{namespaces: map[string]*kapi.Namespace{ns: tc.namespace}}
+		prc.SetPodNodeConstraintsPolicyLister(fakePodNodeConstraintsPolicyLister{policies: tc.policies})
+		attrs := admission.NewAttributesRecord(tc.pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, serviceaccount.UserInfo("", "", ""))
+		var expectedError error
+		if tc.expectedErrorMsg != "" {
+			expectedError = admission.NewForbidden(attrs, fmt.Errorf(tc.expectedErrorMsg))
+		}
+		err := prc.Admit(attrs)
+		switch {
+		case expectedError == nil && err == nil:
+			// continue
+		case expectedError != nil && err != nil && err.Error() != expectedError.Error():
+			tt.Errorf("%s: expected error %q, got: %q", tc.name, expectedError.Error(), err.Error())
+		case expectedError == nil && err != nil:
+			tt.Errorf("%s: expected no error, got: %q", tc.name, err.Error())
+		case expectedError != nil && err == nil:
+			tt.Errorf("%s: expected error %q, no error recieved", tc.name, expectedError.Error())
+		}
+	}
+}
+
+// TestPodNodeConstraintsNodeSelectorLabelLists exercises AllowedNodeSelectorLabels,
+// DeniedNodeSelectorLabels, and AllowedNodeSelectorLabelValues, which are enforced unconditionally
+// regardless of the requesting user's "pods/binding" access.
+func TestPodNodeConstraintsNodeSelectorLabelLists(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	tests := []struct {
+		name             string
+		config           *api.PodNodeConstraintsConfig
+		pod              *kapi.Pod
+		expectedErrorMsg string
+	}{
+		{
+			name:             "denied label is rejected even with pods/bind access",
+			config:           &api.PodNodeConstraintsConfig{DeniedNodeSelectorLabels: []string{"kubernetes.io/hostname"}},
+			pod:              &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"kubernetes.io/hostname": "node1"}}},
+			expectedErrorMsg: `node selector label "kubernetes.io/hostname" is prohibited by policy`,
+		},
+		{
+			name:             "label not on a non-empty allow list is rejected",
+			config:           &api.PodNodeConstraintsConfig{AllowedNodeSelectorLabels: []string{"topology.kubernetes.io/zone"}},
+			pod:              &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"kubernetes.io/hostname": "node1"}}},
+			expectedErrorMsg: `node selector label "kubernetes.io/hostname" is not in the allowed list for your role`,
+		},
+		{
+			name:             "label on the allow list is permitted",
+			config:           &api.PodNodeConstraintsConfig{AllowedNodeSelectorLabels: []string{"topology.kubernetes.io/zone"}},
+			pod:              &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"topology.kubernetes.io/zone": "us-east-1a"}}},
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "value failing the configured regex is rejected",
+			config:           &api.PodNodeConstraintsConfig{AllowedNodeSelectorLabelValues: map[string]string{"topology.kubernetes.io/zone": "^us-east-.*$"}},
+			pod:              &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"topology.kubernetes.io/zone": "us-west-1a"}}},
+			expectedErrorMsg: `node selector label "topology.kubernetes.io/zone" value "us-west-1a" does not match the allowed pattern for your role`,
+		},
+		{
+			name:             "value matching the configured regex is permitted",
+			config:           &api.PodNodeConstraintsConfig{AllowedNodeSelectorLabelValues: map[string]string{"topology.kubernetes.io/zone": "^us-east-.*$"}},
+			pod:              &kapi.Pod{Spec: kapi.PodSpec{NodeSelector: map[string]string{"topology.kubernetes.io/zone": "us-east-1a"}}},
+			expectedErrorMsg: "",
+		},
+	}
+	for _, tc := range tests {
+		fakeOSClient := fakeClient("pods/bind", reviewResponse(true, ""))
+		prc := NewPodNodeConstraints(tc.config)
+		prc.(oadmission.WantsOpenshiftClient).SetOpenshiftClient(fakeOSClient)
+		attrs := admission.NewAttributesRecord(tc.pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, serviceaccount.UserInfo("", "", ""))
+		var expectedError error
+		if tc.expectedErrorMsg != "" {
+			expectedError = admission.NewForbidden(attrs, fmt.Errorf(tc.expectedErrorMsg))
+		}
+		err := prc.Admit(attrs)
+		switch {
+		case expectedError == nil && err == nil:
+			// continue
+		case expectedError != nil && err != nil && err.Error() != expectedError.Error():
+			tt.Errorf("%s: expected error %q, got: %q", tc.name, expectedError.Error(), err.Error())
+		case expectedError == nil && err != nil:
+			tt.Errorf("%s: expected no error, got: %q", tc.name, err.Error())
+		case expectedError != nil && err == nil:
+			tt.Errorf("%s: expected error %q, no error recieved", tc.name, expectedError.Error())
+		}
+	}
+}
+
+// TestPodNodeConstraintsAllowedAffinityTopologyKeys exercises AllowedAffinityTopologyKeys, which
+// is enforced unconditionally regardless of the requesting user's "pods/binding" access.
+func TestPodNodeConstraintsAllowedAffinityTopologyKeys(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	tests := []struct {
+		name             string
+		config           *api.PodNodeConstraintsConfig
+		pod              *kapi.Pod
+		expectedErrorMsg string
+	}{
+		{
+			name:             "topology key not in the allow list is rejected even with pods/bind access",
+			config:           &api.PodNodeConstraintsConfig{AllowedAffinityTopologyKeys: []string{"kubernetes.io/hostname"}},
+			pod:              podAntiAffinityPod("topology.kubernetes.io/zone"),
+			expectedErrorMsg: `pod anti-affinity topology key "topology.kubernetes.io/zone" is not in the allowed list for your role`,
+		},
+		{
+			name:             "topology key in the allow list is permitted",
+			config:           &api.PodNodeConstraintsConfig{AllowedAffinityTopologyKeys: []string{"topology.kubernetes.io/zone"}},
+			pod:              podAntiAffinityPod("topology.kubernetes.io/zone"),
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "empty allow list with ProhibitNodeTargeting restricts to kubernetes.io/hostname",
+			config:           &api.PodNodeConstraintsConfig{ProhibitNodeTargeting: true},
+			pod:              podAntiAffinityPod("topology.kubernetes.io/zone"),
+			expectedErrorMsg: `pod anti-affinity topology key "topology.kubernetes.io/zone" is not in the allowed list for your role`,
+		},
+		{
+			name:             "empty allow list without ProhibitNodeTargeting permits any topology key",
+			config:           &api.PodNodeConstraintsConfig{},
+			pod:              podAntiAffinityPod("topology.kubernetes.io/zone"),
+			expectedErrorMsg: "",
+		},
+	}
+	for _, tc := range tests {
+		fakeOSClient := fakeClient("pods/bind", reviewResponse(true, ""))
+		prc := NewPodNodeConstraints(tc.config)
+		prc.(oadmission.WantsOpenshiftClient).SetOpenshiftClient(fakeOSClient)
+		attrs := admission.NewAttributesRecord(tc.pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, serviceaccount.UserInfo("", "", ""))
+		var expectedError error
+		if tc.expectedErrorMsg != "" {
+			expectedError = admission.NewForbidden(attrs, fmt.Errorf(tc.expectedErrorMsg))
+		}
+		err := prc.Admit(attrs)
+		switch {
+		case expectedError == nil && err == nil:
+			// continue
+		case expectedError != nil && err != nil && err.Error() != expectedError.Error():
+			tt.Errorf("%s: expected error %q, got: %q", tc.name, expectedError.Error(), err.Error())
+		case expectedError == nil && err != nil:
+			tt.Errorf("%s: expected no error, got: %q", tc.name, err.Error())
+		case expectedError != nil && err == nil:
+			tt.Errorf("%s: expected error %q, no error recieved", tc.name, expectedError.Error())
+		}
+	}
+}
+
+func tolerationConfig(allowed ...kapi.Toleration) *api.PodNodeConstraintsConfig {
+	return &api.PodNodeConstraintsConfig{
+		TolerationConstraints: api.TolerationConstraints{AllowedTolerations: allowed},
+	}
+}
+
+func tolerationPod(tolerations ...kapi.Toleration) *kapi.Pod {
+	pod := &kapi.Pod{}
+	pod.Spec.Tolerations = tolerations
+	return pod
+}
+
+// TestPodNodeConstraintsTolerationConstraints exercises TolerationConstraints.AllowedTolerations,
+// which is gated on "pods/binding" access the same way as NodeSelectorLabelBlacklist and
+// ProhibitNodeAffinity, so that a cluster-admin or controller-created pod (e.g. from a DaemonSet,
+// whose controller identity has "pods/binding" access) is exempt.
+func TestPodNodeConstraintsTolerationConstraints(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	masterTaint := kapi.Toleration{Key: "node-role.kubernetes.io/master", Effect: kapi.TaintEffectNoSchedule}
+	tests := []struct {
+		name             string
+		config           *api.PodNodeConstraintsConfig
+		pod              *kapi.Pod
+		userinfo         user.Info
+		reviewResponse   *authorizationapi.SubjectAccessReviewResponse
+		expectedErrorMsg string
+	}{
+		{
+			name:             "toleration not on the allow list is rejected for a user lacking pods/bind access",
+			config:           tolerationConfig(kapi.Toleration{Key: "dedicated", Effect: kapi.TaintEffectNoSchedule}),
+			pod:              tolerationPod(masterTaint),
+			userinfo:         serviceaccount.UserInfo("", "", ""),
+			reviewResponse:   reviewResponse(false, ""),
+			expectedErrorMsg: "node selection by toleration(s) [node-role.kubernetes.io/master:NoSchedule] is prohibited by policy for your role",
+		},
+		{
+			name:             "toleration not on the allow list is permitted for a user with pods/bind access",
+			config:           tolerationConfig(kapi.Toleration{Key: "dedicated", Effect: kapi.TaintEffectNoSchedule}),
+			pod:              tolerationPod(masterTaint),
+			userinfo:         serviceaccount.UserInfo("openshift-infra", "daemonset-controller", ""),
+			reviewResponse:   reviewResponse(true, ""),
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "toleration on the allow list is permitted regardless of pods/bind access",
+			config:           tolerationConfig(masterTaint),
+			pod:              tolerationPod(masterTaint),
+			userinfo:         serviceaccount.UserInfo("", "", ""),
+			reviewResponse:   reviewResponse(false, ""),
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "unset AllowedTolerations permits any toleration",
+			config:           tolerationConfig(),
+			pod:              tolerationPod(masterTaint),
+			userinfo:         serviceaccount.UserInfo("", "", ""),
+			reviewResponse:   reviewResponse(false, ""),
+			expectedErrorMsg: "",
+		},
+	}
+	for _, tc := range tests {
+		fakeOSClient := fakeClient("pods/binding", tc.reviewResponse)
+		prc := NewPodNodeConstraints(tc.config)
+		prc.(oadmission.WantsOpenshiftClient).SetOpenshiftClient(fakeOSClient)
+		attrs := admission.NewAttributesRecord(tc.pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, tc.userinfo)
+		var expectedError error
+		if tc.expectedErrorMsg != "" {
+			expectedError = admission.NewForbidden(attrs, fmt.Errorf(tc.expectedErrorMsg))
+		}
+		err := prc.Admit(attrs)
+		switch {
+		case expectedError == nil && err == nil:
+			// continue
+		case expectedError != nil && err != nil && err.Error() != expectedError.Error():
+			tt.Errorf("%s: expected error %q, got: %q", tc.name, expectedError.Error(), err.Error())
+		case expectedError == nil && err != nil:
+			tt.Errorf("%s: expected no error, got: %q", tc.name, err.Error())
+		case expectedError != nil && err == nil:
+			tt.Errorf("%s: expected error %q, no error recieved", tc.name, expectedError.Error())
+		}
+	}
+}
+
+// fakeProfileResolver is a minimal PodNodeConstraintsProfileResolver standing in for a
+// SecurityContextConstraints-backed implementation, keyed by ServiceAccountName.
+type fakeProfileResolver struct {
+	profiles map[string]string
+}
+
+func (f fakeProfileResolver) ResolveForServiceAccount(namespace, serviceAccountName string) (string, bool) {
+	name, ok := f.profiles[serviceAccountName]
+	return name, ok
+}
+
+// TestPodNodeConstraintsProfiles exercises the precedence among the cluster-wide config, the
+// pod-node-constraints.openshift.io/config namespace annotation, and a PodNodeConstraintsProfileResolver
+// result: SCC-derived (profileResolver) > namespace annotation > cluster default.
+func TestPodNodeConstraintsProfiles(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	permissiveProfile := api.PodNodeConstraintsProfile{AllowNodeName: true}
+	strictProfile := api.PodNodeConstraintsProfile{}
+
+	tests := []struct {
+		name             string
+		namespace        *kapi.Namespace
+		serviceAccount   string
+		resolver         PodNodeConstraintsProfileResolver
+		expectedErrorMsg string
+	}{
+		{
+			name:             "cluster default prohibits nodeName",
+			namespace:        &kapi.Namespace{ObjectMeta: kapi.ObjectMeta{Name: ns}},
+			expectedErrorMsg: "node selection by nodeName is prohibited by policy for your role",
+		},
+		{
+			name: "namespace annotation selects a permissive profile",
+			namespace: &kapi.Namespace{
+				ObjectMeta: kapi.ObjectMeta{
+					Name:        ns,
+					Annotations: map[string]string{namespaceConfigProfileAnnotation: "permissive"},
+				},
+			},
+			expectedErrorMsg: "",
+		},
+		{
+			name: "profile resolver overrides the namespace annotation",
+			namespace: &kapi.Namespace{
+				ObjectMeta: kapi.ObjectMeta{
+					Name:        ns,
+					Annotations: map[string]string{namespaceConfigProfileAnnotation: "permissive"},
+				},
+			},
+			serviceAccount:   "restricted-sa",
+			resolver:         fakeProfileResolver{profiles: map[string]string{"restricted-sa": "strict"}},
+			expectedErrorMsg: "node selection by nodeName is prohibited by policy for your role",
+		},
+	}
+	for _, tc := range tests {
+		config := &api.PodNodeConstraintsConfig{
+			Profiles: map[string]api.PodNodeConstraintsProfile{
+				"permissive": permissiveProfile,
+				"strict":     strictProfile,
+			},
+		}
+		fakeOSClient := fakeClient("pods/binding", reviewResponse(false, ""))
+		prc := NewPodNodeConstraints(config).(*podNodeConstraints)
+		prc.SetOpenshiftClient(fakeOSClient)
+		prc.namespaceLister = fakeNamespaceLister{namespaces: map[string]*kapi.Namespace{ns: tc.namespace}}
+		prc.SetPodNodeConstraintsProfileResolver(tc.resolver)
+
+		pod := nodeNamePod()
+		pod.Spec.ServiceAccountName = tc.serviceAccount
+		attrs := admission.NewAttributesRecord(pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, serviceaccount.UserInfo("", "", ""))
+		var expectedError error
+		if tc.expectedErrorMsg != "" {
+			expectedError = admission.NewForbidden(attrs, fmt.Errorf(tc.expectedErrorMsg))
+		}
+		err := prc.Admit(attrs)
+		switch {
+		case expectedError == nil && err == nil:
+			// continue
+		case expectedError != nil && err != nil && err.Error() != expectedError.Error():
+			tt.Errorf("%s: expected error %q, got: %q", tc.name, expectedError.Error(), err.Error())
+		case expectedError == nil && err != nil:
+			tt.Errorf("%s: expected no error, got: %q", tc.name, err.Error())
+		case expectedError != nil && err == nil:
+			tt.Errorf("%s: expected error %q, no error recieved", tc.name, expectedError.Error())
+		}
+	}
+}
+
+// TestServiceAccountAnnotationProfileResolver exercises the default PodNodeConstraintsProfileResolver
+// that SetInternalKubeInformerFactory wires in, confirming it resolves a profile from the
+// ServiceAccount's own pod-node-constraints.openshift.io/config annotation.
+func TestServiceAccountAnnotationProfileResolver(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	sa := &kapi.ServiceAccount{
+		ObjectMeta: kapi.ObjectMeta{
+			Namespace:   ns,
+			Name:        "restricted-sa",
+			Annotations: map[string]string{namespaceConfigProfileAnnotation: "strict"},
+		},
+	}
+	resolver := &serviceAccountAnnotationProfileResolver{
+		serviceAccountLister: fakeServiceAccountLister{serviceAccounts: map[string]*kapi.ServiceAccount{ns + "/restricted-sa": sa}},
+	}
+
+	if name, ok := resolver.ResolveForServiceAccount(ns, "restricted-sa"); !ok || name != "strict" {
+		tt.Fatalf("expected (strict, true), got (%q, %v)", name, ok)
+	}
+	if _, ok := resolver.ResolveForServiceAccount(ns, "unannotated-sa"); ok {
+		tt.Fatalf("expected no resolution for a ServiceAccount without the annotation")
+	}
+	if _, ok := resolver.ResolveForServiceAccount(ns, ""); ok {
+		tt.Fatalf("expected no resolution for an empty ServiceAccountName")
+	}
+}
+
+func daemonSetWithNodeSelector(nodeSelector map[string]string) *extensions.DaemonSet {
+	ds := &extensions.DaemonSet{}
+	ds.Spec.Template.Spec.NodeSelector = nodeSelector
+	return ds
+}
+
+func statefulSetWithNodeSelector(nodeSelector map[string]string) *apps.StatefulSet {
+	ss := &apps.StatefulSet{}
+	ss.Spec.Template.Spec.NodeSelector = nodeSelector
+	return ss
+}
+
+func cronJobWithNodeSelector(nodeSelector map[string]string) *batch.CronJob {
+	cj := &batch.CronJob{}
+	cj.Spec.JobTemplate.Spec.Template.Spec.NodeSelector = nodeSelector
+	return cj
+}
+
+// TestPodNodeConstraintsControllerResourceKinds exercises the newer controller kinds dispatched
+// through resourcesToAdmit, confirming their pod templates are extracted and enforced the same as
+// a ReplicationController's.
+func TestPodNodeConstraintsControllerResourceKinds(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	tests := []struct {
+		name     string
+		resource unversioned.GroupResource
+		kind     unversioned.GroupKind
+		object   runtime.Object
+	}{
+		{"DaemonSet", extensions.Resource("daemonsets"), extensions.Kind("DaemonSet"), daemonSetWithNodeSelector(map[string]string{"bogus": "frank"})},
+		{"StatefulSet", apps.Resource("statefulsets"), apps.Kind("StatefulSet"), statefulSetWithNodeSelector(map[string]string{"bogus": "frank"})},
+		{"CronJob", batch.Resource("cronjobs"), batch.Kind("CronJob"), cronJobWithNodeSelector(map[string]string{"bogus": "frank"})},
+	}
+	for _, tc := range tests {
+		fakeOSClient := fakeClient("pods/binding", reviewResponse(false, ""))
+		prc := NewPodNodeConstraints(testConfig())
+		prc.(oadmission.WantsOpenshiftClient).SetOpenshiftClient(fakeOSClient)
+		attrs := admission.NewAttributesRecord(tc.object, tc.kind, ns, "test", tc.resource, "", admission.Create, serviceaccount.UserInfo("", "", ""))
+		expectedError := admission.NewForbidden(attrs, fmt.Errorf("node selection by label(s) [bogus] is prohibited by policy for your role"))
+		if err := prc.Admit(attrs); err == nil || err.Error() != expectedError.Error() {
+			tt.Errorf("%s: expected error %q, got: %v", tc.name, expectedError.Error(), err)
+		}
+	}
+}
+
+// TestPodNodeConstraintsUpdateNoOpFastPath confirms that re-submitting a controller resource's pod
+// template across successive Updates from the same identity only issues one SubjectAccessReview,
+// so routine controller reconciles don't hammer the authorizer. This is sarCache's per-identity
+// TTL doing the work, not any per-object cache in Admit itself -- the plugin re-evaluates the
+// effective profile and gating booleans on every request, so a namespace or ServiceAccount profile
+// change takes effect on the very next Update of the same object.
+func TestPodNodeConstraintsUpdateNoOpFastPath(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	var sarCalls int32
+	fakeOSClient, proceed := countingFakeClient(&sarCalls, reviewResponse(true, ""))
+	close(proceed)
+
+	prc := NewPodNodeConstraints(testConfig())
+	prc.(oadmission.WantsOpenshiftClient).SetOpenshiftClient(fakeOSClient)
+
+	rc := func() *kapi.ReplicationController {
+		rc := &kapi.ReplicationController{}
+		rc.Name = "myrc"
+		rc.Spec.Template = &kapi.PodTemplateSpec{Spec: kapi.PodSpec{NodeSelector: map[string]string{"bogus": "frank"}}}
+		return rc
+	}
+
+	attrs := admission.NewAttributesRecord(rc(), kapi.Kind("ReplicationController"), ns, "myrc", kapi.Resource("replicationcontrollers"), "", admission.Update, serviceaccount.UserInfo("openshift-infra", "daemonset-controller", ""))
+	if err := prc.Admit(attrs); err != nil {
+		tt.Fatalf("unexpected error on first update: %v", err)
+	}
+	if calls := atomic.LoadInt32(&sarCalls); calls != 1 {
+		tt.Fatalf("expected exactly 1 SubjectAccessReview call after the first update, got: %d", calls)
+	}
+
+	// Re-admit the same resource, name, and pod template from the same identity: sarCache should
+	// serve the second bind-access check from its cache rather than issuing another
+	// SubjectAccessReview.
+	attrs = admission.NewAttributesRecord(rc(), kapi.Kind("ReplicationController"), ns, "myrc", kapi.Resource("replicationcontrollers"), "", admission.Update, serviceaccount.UserInfo("openshift-infra", "daemonset-controller", ""))
+	if err := prc.Admit(attrs); err != nil {
+		tt.Fatalf("unexpected error on no-op update: %v", err)
+	}
+	if calls := atomic.LoadInt32(&sarCalls); calls != 1 {
+		tt.Errorf("expected no additional SubjectAccessReview call for an unchanged pod template update, got: %d", calls)
+	}
+}
+
+// TestPodNodeConstraintsUpdateReEvaluatesProfileEveryRequest confirms that Admit re-resolves the
+// effective profile on every request rather than reusing a decision cached against the pod
+// template: tightening a namespace's profile between two otherwise-identical Updates of the same
+// resource must be enforced against the second one even though neither the pod template nor the
+// requesting identity changed.
+func TestPodNodeConstraintsUpdateReEvaluatesProfileEveryRequest(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	config := &api.PodNodeConstraintsConfig{
+		Profiles: map[string]api.PodNodeConstraintsProfile{
+			"strict": {DeniedNodeSelectorLabels: []string{"bogus"}},
+		},
+	}
+	namespace := &kapi.Namespace{ObjectMeta: kapi.ObjectMeta{Name: ns}}
+
+	fakeOSClient := fakeClient("pods/bind", reviewResponse(true, ""))
+	prc := NewPodNodeConstraints(config).(*podNodeConstraints)
+	prc.SetOpenshiftClient(fakeOSClient)
+	prc.namespaceLister = fakeNamespaceLister{namespaces: map[string]*kapi.Namespace{ns: namespace}}
+
+	rc := func() *kapi.ReplicationController {
+		rc := &kapi.ReplicationController{}
+		rc.Name = "myrc"
+		rc.Spec.Template = &kapi.PodTemplateSpec{Spec: kapi.PodSpec{NodeSelector: map[string]string{"bogus": "frank"}}}
+		return rc
+	}
+
+	attrs := admission.NewAttributesRecord(rc(), kapi.Kind("ReplicationController"), ns, "myrc", kapi.Resource("replicationcontrollers"), "", admission.Update, serviceaccount.UserInfo("", "", ""))
+	if err := prc.Admit(attrs); err != nil {
+		tt.Fatalf("unexpected error on first update: %v", err)
+	}
+
+	// Tighten the effective profile for this namespace without changing the pod template or the
+	// requesting identity: the unconditional deny list must still be consulted fresh on the next
+	// Update and reject it.
+	namespace.Annotations = map[string]string{namespaceConfigProfileAnnotation: "strict"}
+
+	attrs = admission.NewAttributesRecord(rc(), kapi.Kind("ReplicationController"), ns, "myrc", kapi.Resource("replicationcontrollers"), "", admission.Update, serviceaccount.UserInfo("", "", ""))
+	err := prc.Admit(attrs)
+	expectedError := admission.NewForbidden(attrs, fmt.Errorf(`node selector label "bogus" is prohibited by policy`))
+	if err == nil || err.Error() != expectedError.Error() {
+		tt.Fatalf("expected tightened profile to be enforced on the repeated update, got: %v", err)
+	}
+}
+
+// TestPodNodeConstraintsSARCacheCollapsesConcurrentCalls fires many concurrent admissions for the
+// same user/namespace/resource and verifies they are served by a single SubjectAccessReview, both
+// to exercise the cache hit path and the in-flight call de-duplication for the first, uncached
+// lookup.
+func TestPodNodeConstraintsSARCacheCollapsesConcurrentCalls(tt *testing.T) {
+	ns := kapi.NamespaceDefault
+	var sarCalls int32
+	fakeOSClient, proceed := countingFakeClient(&sarCalls, reviewResponse(false, ""))
+
+	prc := NewPodNodeConstraints(testConfig())
+	prc.(oadmission.WantsOpenshiftClient).SetOpenshiftClient(fakeOSClient)
+
+	const concurrency = 25
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			attrs := admission.NewAttributesRecord(nodeSelectorPod(), kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, serviceaccount.UserInfo("", "", ""))
+			if err := prc.Admit(attrs); err == nil {
+				tt.Errorf("expected forbidden error for user without pods/bind access, got none")
+			}
+		}()
+	}
+	close(proceed)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&sarCalls); calls != 1 {
+		tt.Errorf("expected exactly 1 SubjectAccessReview call, got: %d", calls)
+	}
+}
+
+// countingFakeClient behaves like fakeClient, but increments callCount for every
+// LocalSubjectAccessReviews call and blocks each call on proceed so concurrent admissions race
+// into the cache's in-flight de-duplication rather than serializing through the fake reactor.
+func countingFakeClient(callCount *int32, reviewResponse *authorizationapi.SubjectAccessReviewResponse) (client.Interface, chan struct{}) {
+	proceed := make(chan struct{})
+	fake := &testclient.Fake{}
+	fake.AddReactor("create", "localsubjectaccessreviews", func(action ktestclient.Action) (handled bool, ret runtime.Object, err error) {
+		<-proceed
+		atomic.AddInt32(callCount, 1)
+		return true, reviewResponse, nil
+	})
+	return fake, proceed
+}
+
 func fakeClient(expectedResource string, reviewResponse *authorizationapi.SubjectAccessReviewResponse) client.Interface {
 	emptyResponse := &authorizationapi.SubjectAccessReviewResponse{}
 
@@ -194,3 +984,29 @@ nodeSelectorLabelBlacklist:
 		t.Fatalf("NodeSelectorLabelBlacklist didn't take specified value")
 	}
 }
+
+func TestReadConfigRejectsInvalidAllowedNodeSelectorLabelValues(t *testing.T) {
+	configStr := `apiVersion: v1
+kind: PodNodeConstraintsConfig
+allowedNodeSelectorLabelValues:
+  topology.kubernetes.io/zone: "("
+`
+	buf := bytes.NewBufferString(configStr)
+	if _, err := readConfig(buf); err == nil {
+		t.Fatalf("expected an error reading config with an unparseable regular expression")
+	}
+}
+
+func TestReadConfigRejectsInvalidProfileAllowedNodeSelectorLabelValues(t *testing.T) {
+	configStr := `apiVersion: v1
+kind: PodNodeConstraintsConfig
+profiles:
+  strict:
+    allowedNodeSelectorLabelValues:
+      topology.kubernetes.io/zone: "("
+`
+	buf := bytes.NewBufferString(configStr)
+	if _, err := readConfig(buf); err == nil {
+		t.Fatalf("expected an error reading config with an unparseable regular expression in a profile")
+	}
+}
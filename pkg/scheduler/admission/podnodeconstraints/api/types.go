@@ -1,6 +1,7 @@
 package api
 
 import (
+	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 )
 
@@ -10,6 +11,124 @@ import (
 // pod requests.
 type PodNodeConstraintsConfig struct {
 	unversioned.TypeMeta
+	// NodeSelectorLabelBlacklist is the list of node selector labels which require "pods/binding"
+	// permission to set in a pod spec.
+	NodeSelectorLabelBlacklist []string
 	// ProhibitNodeTargeting determines if policy allows targeting specific nodes via nodeName or nodeSelector in the pod spec.
 	ProhibitNodeTargeting bool
+	// ProhibitNodeAffinity determines if policy requires "pods/binding" permission to target specific
+	// nodes via the NodeAffinity, PodAffinity, or PodAntiAffinity terms of a pod's affinity.
+	ProhibitNodeAffinity bool
+	// AffinityTopologyKeyBlacklist is the list of pod (anti-)affinity topology keys which require
+	// "pods/binding" permission to use in a pod spec. When empty and ProhibitNodeAffinity is true,
+	// every topology key other than kubernetes.io/hostname requires "pods/binding" permission.
+	AffinityTopologyKeyBlacklist []string
+	// DefaultNodeSelector is merged with, and overridden by, a namespace's openshift.io/node-selector
+	// annotation by the PodNodeConstraintsDefaulter admission plugin to default the nodeSelector of
+	// pod templates that don't already specify one.
+	DefaultNodeSelector map[string]string
+	// SARCacheSize is the maximum number of SubjectAccessReview responses the plugin memoizes.
+	// Defaults to 1024 when unset.
+	SARCacheSize int
+	// SARCacheTTL is how long a positive SubjectAccessReview response is cached. Defaults to 30s
+	// when unset.
+	SARCacheTTL unversioned.Duration
+	// SARCacheNegativeTTL is how long a negative SubjectAccessReview response is cached. Defaults
+	// to 5s when unset.
+	SARCacheNegativeTTL unversioned.Duration
+	// AllowedNodeSelectorLabels, when non-empty, is the exclusive list of node selector labels any
+	// user may set, regardless of "pods/binding" access. It is checked unconditionally, unlike
+	// NodeSelectorLabelBlacklist which only gates users lacking "pods/binding" access.
+	AllowedNodeSelectorLabels []string
+	// DeniedNodeSelectorLabels is the list of node selector labels no user may set, regardless of
+	// AllowedNodeSelectorLabels or "pods/binding" access.
+	DeniedNodeSelectorLabels []string
+	// AllowedNodeSelectorLabelValues optionally restricts the values accepted for a node selector
+	// label to those matching a regular expression, keyed by label.
+	AllowedNodeSelectorLabelValues map[string]string
+	// AllowedAffinityTopologyKeys, when non-empty, is the exclusive list of pod (anti-)affinity
+	// topology keys any user may use, regardless of "pods/binding" access. When empty and
+	// ProhibitNodeTargeting is true, only hostnameTopologyKey is allowed. This check is
+	// unconditional, unlike AffinityTopologyKeyBlacklist which only gates users lacking
+	// "pods/binding" access.
+	AllowedAffinityTopologyKeys []string
+	// TolerationConstraints restricts which taint tolerations require "pods/binding" access,
+	// analogous to NodeSelectorLabelBlacklist and AffinityTopologyKeyBlacklist.
+	TolerationConstraints TolerationConstraints
+	// Profiles is a set of named PodNodeConstraintsProfile overrides, keyed by profile name. A
+	// namespace opts into one via its pod-node-constraints.openshift.io/config annotation, and a
+	// pod's ServiceAccount can be resolved to one at a higher precedence (see
+	// PodNodeConstraintsProfileResolver). A profile replaces the cluster-wide fields it covers
+	// wholesale; it does not merge field-by-field with the cluster default.
+	Profiles map[string]PodNodeConstraintsProfile
+}
+
+// PodNodeConstraintsProfile is a named, overridable bundle of the per-tenant PodNodeConstraintsConfig
+// fields. See PodNodeConstraintsConfig.Profiles.
+type PodNodeConstraintsProfile struct {
+	// NodeSelectorLabelBlacklist overrides PodNodeConstraintsConfig.NodeSelectorLabelBlacklist.
+	NodeSelectorLabelBlacklist []string
+	// AllowNodeName overrides the cluster-wide ProhibitNodeTargeting for nodeName specifically,
+	// the same way the scheduler.openshift.io/allow-node-name namespace annotation does.
+	AllowNodeName bool
+	// AllowedNodeSelectorLabels overrides PodNodeConstraintsConfig.AllowedNodeSelectorLabels.
+	AllowedNodeSelectorLabels []string
+	// DeniedNodeSelectorLabels overrides PodNodeConstraintsConfig.DeniedNodeSelectorLabels.
+	DeniedNodeSelectorLabels []string
+	// AllowedNodeSelectorLabelValues overrides PodNodeConstraintsConfig.AllowedNodeSelectorLabelValues.
+	AllowedNodeSelectorLabelValues map[string]string
+	// AllowedAffinityTopologyKeys overrides PodNodeConstraintsConfig.AllowedAffinityTopologyKeys.
+	AllowedAffinityTopologyKeys []string
+	// AllowedTolerations overrides PodNodeConstraintsConfig.TolerationConstraints.AllowedTolerations.
+	AllowedTolerations []kapi.Toleration
+}
+
+// TolerationConstraints restricts which taint key/effect pairs a user may tolerate via a pod's
+// Tolerations (or the legacy scheduler.alpha.kubernetes.io/tolerations annotation) without
+// "pods/binding" access.
+type TolerationConstraints struct {
+	// AllowedTolerations is the list of taint key/effect pairs any user may tolerate. A toleration
+	// whose key/effect is not on this list requires "pods/binding" access. When empty, no
+	// toleration requires "pods/binding" access.
+	AllowedTolerations []kapi.Toleration
+}
+
+// PodNodeConstraintsPolicy is a cluster-scoped override of PodNodeConstraintsConfig for the
+// namespaces matched by Spec.NamespaceSelector. It lets cluster admins loosen the cluster-wide
+// policy for a subset of namespaces (e.g. daemonset-like workloads or GPU pinning) without
+// disabling enforcement everywhere.
+//
+// This type defines the wire format only; it takes effect in a running cluster solely through a
+// podnodeconstraints.PodNodeConstraintsPolicyLister wired by
+// podnodeconstraints.SetPodNodeConstraintsPolicyLister, which in turn requires REST storage for
+// this resource to be registered with the API server. See
+// podnodeconstraints.BootstrapClusterRoles for the default RBAC that registration should install.
+type PodNodeConstraintsPolicy struct {
+	unversioned.TypeMeta
+	kapi.ObjectMeta
+
+	Spec PodNodeConstraintsPolicySpec
+}
+
+// PodNodeConstraintsPolicySpec describes the namespaces a PodNodeConstraintsPolicy applies to
+// and the overrides it grants them.
+type PodNodeConstraintsPolicySpec struct {
+	// NamespaceSelector selects the namespaces this policy overrides the cluster-wide config for.
+	NamespaceSelector unversioned.LabelSelector
+
+	// NodeSelectorLabelBlacklist, when non-nil, replaces the cluster-wide
+	// PodNodeConstraintsConfig.NodeSelectorLabelBlacklist for matching namespaces.
+	NodeSelectorLabelBlacklist []string
+
+	// AllowNodeName permits nodeName targeting in matching namespaces regardless of the
+	// cluster-wide ProhibitNodeTargeting setting.
+	AllowNodeName bool
+}
+
+// PodNodeConstraintsPolicyList is a list of PodNodeConstraintsPolicy objects.
+type PodNodeConstraintsPolicyList struct {
+	unversioned.TypeMeta
+	unversioned.ListMeta
+
+	Items []PodNodeConstraintsPolicy
 }
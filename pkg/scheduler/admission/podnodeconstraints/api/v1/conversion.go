@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"k8s.io/kubernetes/pkg/conversion"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	newer "github.com/openshift/origin/pkg/scheduler/admission/podnodeconstraints/api"
+)
+
+func addConversionFuncs(scheme *runtime.Scheme) error {
+	return scheme.AddConversionFuncs(
+		convert_v1_PodNodeConstraintsConfig_To_api_PodNodeConstraintsConfig,
+		convert_api_PodNodeConstraintsConfig_To_v1_PodNodeConstraintsConfig,
+		convert_v1_PodNodeConstraintsPolicy_To_api_PodNodeConstraintsPolicy,
+		convert_api_PodNodeConstraintsPolicy_To_v1_PodNodeConstraintsPolicy,
+		convert_v1_PodNodeConstraintsPolicyList_To_api_PodNodeConstraintsPolicyList,
+		convert_api_PodNodeConstraintsPolicyList_To_v1_PodNodeConstraintsPolicyList,
+	)
+}
+
+func convert_v1_PodNodeConstraintsConfig_To_api_PodNodeConstraintsConfig(in *PodNodeConstraintsConfig, out *newer.PodNodeConstraintsConfig, s conversion.Scope) error {
+	out.TypeMeta = in.TypeMeta
+	out.NodeSelectorLabelBlacklist = in.NodeSelectorLabelBlacklist
+	out.ProhibitNodeTargeting = in.ProhibitNodeTargeting
+	out.ProhibitNodeAffinity = in.ProhibitNodeAffinity
+	out.AffinityTopologyKeyBlacklist = in.AffinityTopologyKeyBlacklist
+	out.DefaultNodeSelector = in.DefaultNodeSelector
+	out.SARCacheSize = in.SARCacheSize
+	out.SARCacheTTL = in.SARCacheTTL
+	out.SARCacheNegativeTTL = in.SARCacheNegativeTTL
+	out.AllowedNodeSelectorLabels = in.AllowedNodeSelectorLabels
+	out.DeniedNodeSelectorLabels = in.DeniedNodeSelectorLabels
+	out.AllowedNodeSelectorLabelValues = in.AllowedNodeSelectorLabelValues
+	out.AllowedAffinityTopologyKeys = in.AllowedAffinityTopologyKeys
+	out.TolerationConstraints = newer.TolerationConstraints{AllowedTolerations: in.TolerationConstraints.AllowedTolerations}
+	out.Profiles = convert_v1_PodNodeConstraintsProfiles_To_api(in.Profiles)
+	return nil
+}
+
+func convert_v1_PodNodeConstraintsProfiles_To_api(in map[string]PodNodeConstraintsProfile) map[string]newer.PodNodeConstraintsProfile {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]newer.PodNodeConstraintsProfile, len(in))
+	for name, profile := range in {
+		out[name] = newer.PodNodeConstraintsProfile{
+			NodeSelectorLabelBlacklist:     profile.NodeSelectorLabelBlacklist,
+			AllowNodeName:                  profile.AllowNodeName,
+			AllowedNodeSelectorLabels:      profile.AllowedNodeSelectorLabels,
+			DeniedNodeSelectorLabels:       profile.DeniedNodeSelectorLabels,
+			AllowedNodeSelectorLabelValues: profile.AllowedNodeSelectorLabelValues,
+			AllowedAffinityTopologyKeys:    profile.AllowedAffinityTopologyKeys,
+			AllowedTolerations:             profile.AllowedTolerations,
+		}
+	}
+	return out
+}
+
+func convert_api_PodNodeConstraintsConfig_To_v1_PodNodeConstraintsConfig(in *newer.PodNodeConstraintsConfig, out *PodNodeConstraintsConfig, s conversion.Scope) error {
+	out.TypeMeta = in.TypeMeta
+	out.NodeSelectorLabelBlacklist = in.NodeSelectorLabelBlacklist
+	out.ProhibitNodeTargeting = in.ProhibitNodeTargeting
+	out.ProhibitNodeAffinity = in.ProhibitNodeAffinity
+	out.AffinityTopologyKeyBlacklist = in.AffinityTopologyKeyBlacklist
+	out.DefaultNodeSelector = in.DefaultNodeSelector
+	out.SARCacheSize = in.SARCacheSize
+	out.SARCacheTTL = in.SARCacheTTL
+	out.SARCacheNegativeTTL = in.SARCacheNegativeTTL
+	out.AllowedNodeSelectorLabels = in.AllowedNodeSelectorLabels
+	out.DeniedNodeSelectorLabels = in.DeniedNodeSelectorLabels
+	out.AllowedNodeSelectorLabelValues = in.AllowedNodeSelectorLabelValues
+	out.AllowedAffinityTopologyKeys = in.AllowedAffinityTopologyKeys
+	out.TolerationConstraints = TolerationConstraints{AllowedTolerations: in.TolerationConstraints.AllowedTolerations}
+	out.Profiles = convert_api_PodNodeConstraintsProfiles_To_v1(in.Profiles)
+	return nil
+}
+
+func convert_api_PodNodeConstraintsProfiles_To_v1(in map[string]newer.PodNodeConstraintsProfile) map[string]PodNodeConstraintsProfile {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]PodNodeConstraintsProfile, len(in))
+	for name, profile := range in {
+		out[name] = PodNodeConstraintsProfile{
+			NodeSelectorLabelBlacklist:     profile.NodeSelectorLabelBlacklist,
+			AllowNodeName:                  profile.AllowNodeName,
+			AllowedNodeSelectorLabels:      profile.AllowedNodeSelectorLabels,
+			DeniedNodeSelectorLabels:       profile.DeniedNodeSelectorLabels,
+			AllowedNodeSelectorLabelValues: profile.AllowedNodeSelectorLabelValues,
+			AllowedAffinityTopologyKeys:    profile.AllowedAffinityTopologyKeys,
+			AllowedTolerations:             profile.AllowedTolerations,
+		}
+	}
+	return out
+}
+
+func convert_v1_PodNodeConstraintsPolicy_To_api_PodNodeConstraintsPolicy(in *PodNodeConstraintsPolicy, out *newer.PodNodeConstraintsPolicy, s conversion.Scope) error {
+	out.TypeMeta = in.TypeMeta
+	out.ObjectMeta = in.ObjectMeta
+	out.Spec = newer.PodNodeConstraintsPolicySpec{
+		NamespaceSelector:          in.Spec.NamespaceSelector,
+		NodeSelectorLabelBlacklist: in.Spec.NodeSelectorLabelBlacklist,
+		AllowNodeName:              in.Spec.AllowNodeName,
+	}
+	return nil
+}
+
+func convert_api_PodNodeConstraintsPolicy_To_v1_PodNodeConstraintsPolicy(in *newer.PodNodeConstraintsPolicy, out *PodNodeConstraintsPolicy, s conversion.Scope) error {
+	out.TypeMeta = in.TypeMeta
+	out.ObjectMeta = in.ObjectMeta
+	out.Spec = PodNodeConstraintsPolicySpec{
+		NamespaceSelector:          in.Spec.NamespaceSelector,
+		NodeSelectorLabelBlacklist: in.Spec.NodeSelectorLabelBlacklist,
+		AllowNodeName:              in.Spec.AllowNodeName,
+	}
+	return nil
+}
+
+func convert_v1_PodNodeConstraintsPolicyList_To_api_PodNodeConstraintsPolicyList(in *PodNodeConstraintsPolicyList, out *newer.PodNodeConstraintsPolicyList, s conversion.Scope) error {
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	out.Items = make([]newer.PodNodeConstraintsPolicy, len(in.Items))
+	for i := range in.Items {
+		if err := convert_v1_PodNodeConstraintsPolicy_To_api_PodNodeConstraintsPolicy(&in.Items[i], &out.Items[i], s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func convert_api_PodNodeConstraintsPolicyList_To_v1_PodNodeConstraintsPolicyList(in *newer.PodNodeConstraintsPolicyList, out *PodNodeConstraintsPolicyList, s conversion.Scope) error {
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	out.Items = make([]PodNodeConstraintsPolicy, len(in.Items))
+	for i := range in.Items {
+		if err := convert_api_PodNodeConstraintsPolicy_To_v1_PodNodeConstraintsPolicy(&in.Items[i], &out.Items[i], s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"testing"
 
 	kapi "k8s.io/kubernetes/pkg/api"
-	"k8s.io/kubernetes/pkg/util/sets"
 
 	"github.com/openshift/origin/pkg/scheduler/admission/podnodeconstraints/api"
 	versioned "github.com/openshift/origin/pkg/scheduler/admission/podnodeconstraints/api/v1"
@@ -12,10 +11,14 @@ import (
 
 func TestConversions(t *testing.T) {
 	input := &versioned.PodNodeConstraintsConfig{
-		NodeSelectorLabelBlacklist: []string{"test"},
+		NodeSelectorLabelBlacklist:   []string{"test"},
+		ProhibitNodeAffinity:         true,
+		AffinityTopologyKeyBlacklist: []string{"topology.kubernetes.io/zone"},
 	}
 	expected := api.PodNodeConstraintsConfig{
-		NodeSelectorLabelBlacklist: sets.NewString([]string{"test"}...),
+		NodeSelectorLabelBlacklist:   []string{"test"},
+		ProhibitNodeAffinity:         true,
+		AffinityTopologyKeyBlacklist: []string{"topology.kubernetes.io/zone"},
 	}
 	output := &api.PodNodeConstraintsConfig{}
 	err := kapi.Scheme.Convert(input, output)
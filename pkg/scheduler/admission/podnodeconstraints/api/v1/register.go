@@ -0,0 +1,28 @@
+package v1
+
+import (
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// GroupName is the group name for this API.
+const GroupName = ""
+
+// SchemeGroupVersion is the group version used to register these objects.
+var SchemeGroupVersion = unversioned.GroupVersion{Group: GroupName, Version: "v1"}
+
+func addKnownTypes(scheme *runtime.Scheme) {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&PodNodeConstraintsConfig{},
+		&PodNodeConstraintsPolicy{},
+		&PodNodeConstraintsPolicyList{},
+	)
+}
+
+func init() {
+	addKnownTypes(kapi.Scheme)
+	if err := addConversionFuncs(kapi.Scheme); err != nil {
+		panic(err)
+	}
+}
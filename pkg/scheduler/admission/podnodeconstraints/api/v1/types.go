@@ -0,0 +1,126 @@
+package v1
+
+import (
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+// PodNodeConstraintsConfig is the configuration for the pod node name
+// and node selector constraint plug-in. It contains a boolean to
+// allow or prohibit the use of nodeName and nodeSelector fields in
+// pod requests.
+type PodNodeConstraintsConfig struct {
+	unversioned.TypeMeta `json:",inline"`
+	// NodeSelectorLabelBlacklist is the list of node selector labels which require "pods/binding"
+	// permission to set in a pod spec.
+	NodeSelectorLabelBlacklist []string `json:"nodeSelectorLabelBlacklist"`
+	// ProhibitNodeTargeting determines if policy allows targeting specific nodes via nodeName or nodeSelector in the pod spec.
+	ProhibitNodeTargeting bool `json:"prohibitNodeTargeting"`
+	// ProhibitNodeAffinity determines if policy requires "pods/binding" permission to target specific
+	// nodes via the NodeAffinity, PodAffinity, or PodAntiAffinity terms of a pod's affinity.
+	ProhibitNodeAffinity bool `json:"prohibitNodeAffinity"`
+	// AffinityTopologyKeyBlacklist is the list of pod (anti-)affinity topology keys which require
+	// "pods/binding" permission to use in a pod spec. When empty and ProhibitNodeAffinity is true,
+	// every topology key other than kubernetes.io/hostname requires "pods/binding" permission.
+	AffinityTopologyKeyBlacklist []string `json:"affinityTopologyKeyBlacklist"`
+	// DefaultNodeSelector is merged with, and overridden by, a namespace's openshift.io/node-selector
+	// annotation by the PodNodeConstraintsDefaulter admission plugin to default the nodeSelector of
+	// pod templates that don't already specify one.
+	DefaultNodeSelector map[string]string `json:"defaultNodeSelector,omitempty"`
+	// SARCacheSize is the maximum number of SubjectAccessReview responses the plugin memoizes.
+	// Defaults to 1024 when unset.
+	SARCacheSize int `json:"sarCacheSize,omitempty"`
+	// SARCacheTTL is how long a positive SubjectAccessReview response is cached. Defaults to 30s
+	// when unset.
+	SARCacheTTL unversioned.Duration `json:"sarCacheTTL,omitempty"`
+	// SARCacheNegativeTTL is how long a negative SubjectAccessReview response is cached. Defaults
+	// to 5s when unset.
+	SARCacheNegativeTTL unversioned.Duration `json:"sarCacheNegativeTTL,omitempty"`
+	// AllowedNodeSelectorLabels, when non-empty, is the exclusive list of node selector labels any
+	// user may set, regardless of "pods/binding" access. It is checked unconditionally, unlike
+	// NodeSelectorLabelBlacklist which only gates users lacking "pods/binding" access.
+	AllowedNodeSelectorLabels []string `json:"allowedNodeSelectorLabels,omitempty"`
+	// DeniedNodeSelectorLabels is the list of node selector labels no user may set, regardless of
+	// AllowedNodeSelectorLabels or "pods/binding" access.
+	DeniedNodeSelectorLabels []string `json:"deniedNodeSelectorLabels,omitempty"`
+	// AllowedNodeSelectorLabelValues optionally restricts the values accepted for a node selector
+	// label to those matching a regular expression, keyed by label.
+	AllowedNodeSelectorLabelValues map[string]string `json:"allowedNodeSelectorLabelValues,omitempty"`
+	// AllowedAffinityTopologyKeys, when non-empty, is the exclusive list of pod (anti-)affinity
+	// topology keys any user may use, regardless of "pods/binding" access. When empty and
+	// ProhibitNodeTargeting is true, only kubernetes.io/hostname is allowed. This check is
+	// unconditional, unlike AffinityTopologyKeyBlacklist which only gates users lacking
+	// "pods/binding" access.
+	AllowedAffinityTopologyKeys []string `json:"allowedAffinityTopologyKeys,omitempty"`
+	// TolerationConstraints restricts which taint tolerations require "pods/binding" access,
+	// analogous to NodeSelectorLabelBlacklist and AffinityTopologyKeyBlacklist.
+	TolerationConstraints TolerationConstraints `json:"tolerationConstraints,omitempty"`
+	// Profiles is a set of named PodNodeConstraintsProfile overrides, keyed by profile name. A
+	// namespace opts into one via its pod-node-constraints.openshift.io/config annotation, and a
+	// pod's ServiceAccount can be resolved to one at a higher precedence (see
+	// PodNodeConstraintsProfileResolver). A profile replaces the cluster-wide fields it covers
+	// wholesale; it does not merge field-by-field with the cluster default.
+	Profiles map[string]PodNodeConstraintsProfile `json:"profiles,omitempty"`
+}
+
+// PodNodeConstraintsProfile is a named, overridable bundle of the per-tenant PodNodeConstraintsConfig
+// fields. See PodNodeConstraintsConfig.Profiles.
+type PodNodeConstraintsProfile struct {
+	// NodeSelectorLabelBlacklist overrides PodNodeConstraintsConfig.NodeSelectorLabelBlacklist.
+	NodeSelectorLabelBlacklist []string `json:"nodeSelectorLabelBlacklist,omitempty"`
+	// AllowNodeName overrides the cluster-wide ProhibitNodeTargeting for nodeName specifically,
+	// the same way the scheduler.openshift.io/allow-node-name namespace annotation does.
+	AllowNodeName bool `json:"allowNodeName,omitempty"`
+	// AllowedNodeSelectorLabels overrides PodNodeConstraintsConfig.AllowedNodeSelectorLabels.
+	AllowedNodeSelectorLabels []string `json:"allowedNodeSelectorLabels,omitempty"`
+	// DeniedNodeSelectorLabels overrides PodNodeConstraintsConfig.DeniedNodeSelectorLabels.
+	DeniedNodeSelectorLabels []string `json:"deniedNodeSelectorLabels,omitempty"`
+	// AllowedNodeSelectorLabelValues overrides PodNodeConstraintsConfig.AllowedNodeSelectorLabelValues.
+	AllowedNodeSelectorLabelValues map[string]string `json:"allowedNodeSelectorLabelValues,omitempty"`
+	// AllowedAffinityTopologyKeys overrides PodNodeConstraintsConfig.AllowedAffinityTopologyKeys.
+	AllowedAffinityTopologyKeys []string `json:"allowedAffinityTopologyKeys,omitempty"`
+	// AllowedTolerations overrides PodNodeConstraintsConfig.TolerationConstraints.AllowedTolerations.
+	AllowedTolerations []kapi.Toleration `json:"allowedTolerations,omitempty"`
+}
+
+// TolerationConstraints restricts which taint key/effect pairs a user may tolerate via a pod's
+// Tolerations (or the legacy scheduler.alpha.kubernetes.io/tolerations annotation) without
+// "pods/binding" access.
+type TolerationConstraints struct {
+	// AllowedTolerations is the list of taint key/effect pairs any user may tolerate. A toleration
+	// whose key/effect is not on this list requires "pods/binding" access. When empty, no
+	// toleration requires "pods/binding" access.
+	AllowedTolerations []kapi.Toleration `json:"allowedTolerations,omitempty"`
+}
+
+// PodNodeConstraintsPolicy is a cluster-scoped override of PodNodeConstraintsConfig for the
+// namespaces matched by Spec.NamespaceSelector. It lets cluster admins loosen the cluster-wide
+// policy for a subset of namespaces (e.g. daemonset-like workloads or GPU pinning) without
+// disabling enforcement everywhere.
+type PodNodeConstraintsPolicy struct {
+	unversioned.TypeMeta `json:",inline"`
+	kapi.ObjectMeta      `json:"metadata,omitempty"`
+
+	Spec PodNodeConstraintsPolicySpec `json:"spec"`
+}
+
+// PodNodeConstraintsPolicySpec describes the namespaces a PodNodeConstraintsPolicy applies to
+// and the overrides it grants them.
+type PodNodeConstraintsPolicySpec struct {
+	// NamespaceSelector selects the namespaces this policy overrides the cluster-wide config for.
+	NamespaceSelector unversioned.LabelSelector `json:"namespaceSelector"`
+	// NodeSelectorLabelBlacklist, when non-nil, replaces the cluster-wide
+	// PodNodeConstraintsConfig.NodeSelectorLabelBlacklist for matching namespaces.
+	NodeSelectorLabelBlacklist []string `json:"nodeSelectorLabelBlacklist,omitempty"`
+	// AllowNodeName permits nodeName targeting in matching namespaces regardless of the
+	// cluster-wide ProhibitNodeTargeting setting.
+	AllowNodeName bool `json:"allowNodeName,omitempty"`
+}
+
+// PodNodeConstraintsPolicyList is a list of PodNodeConstraintsPolicy objects.
+type PodNodeConstraintsPolicyList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+
+	Items []PodNodeConstraintsPolicy `json:"items"`
+}
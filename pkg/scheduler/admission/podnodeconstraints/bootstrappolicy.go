@@ -0,0 +1,43 @@
+package podnodeconstraints
+
+import (
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+)
+
+// PodNodeConstraintsPolicyResource is the plural resource name PodNodeConstraintsPolicy objects
+// are served under once REST storage for the type is registered with the API server.
+const PodNodeConstraintsPolicyResource = "podnodeconstraintspolicies"
+
+// BootstrapClusterRoles returns the default ClusterRoles for the PodNodeConstraintsPolicy
+// resource: cluster admins get full access, and a read-only role lets any consumer confirm which
+// overrides are in effect for a namespace. These aren't wired into any running cluster by this
+// package -- there's no bootstrap policy reconciliation here to merge them into -- callers
+// standing up PodNodeConstraintsPolicy's REST storage are expected to fold them into the
+// cluster's bootstrap policy the same way other admin-scoped resources' default roles are.
+func BootstrapClusterRoles() []authorizationapi.ClusterRole {
+	return []authorizationapi.ClusterRole{
+		{
+			ObjectMeta: kapi.ObjectMeta{Name: "system:pod-node-constraints-policy-admin"},
+			Rules: []authorizationapi.PolicyRule{
+				{
+					Verbs:     sets.NewString("get", "list", "watch", "create", "update", "patch", "delete"),
+					APIGroups: []string{""},
+					Resources: sets.NewString(PodNodeConstraintsPolicyResource),
+				},
+			},
+		},
+		{
+			ObjectMeta: kapi.ObjectMeta{Name: "system:pod-node-constraints-policy-viewer"},
+			Rules: []authorizationapi.PolicyRule{
+				{
+					Verbs:     sets.NewString("get", "list", "watch"),
+					APIGroups: []string{""},
+					Resources: sets.NewString(PodNodeConstraintsPolicyResource),
+				},
+			},
+		},
+	}
+}
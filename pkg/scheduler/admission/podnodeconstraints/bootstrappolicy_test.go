@@ -0,0 +1,21 @@
+package podnodeconstraints
+
+import "testing"
+
+func TestBootstrapClusterRolesCoverPodNodeConstraintsPolicyResource(t *testing.T) {
+	roles := BootstrapClusterRoles()
+	if len(roles) == 0 {
+		t.Fatalf("expected at least one bootstrap ClusterRole")
+	}
+	for _, role := range roles {
+		if len(role.Rules) == 0 {
+			t.Errorf("%s: expected at least one rule", role.Name)
+			continue
+		}
+		for _, rule := range role.Rules {
+			if !rule.Resources.Has(PodNodeConstraintsPolicyResource) {
+				t.Errorf("%s: expected a rule covering resource %q, got: %v", role.Name, PodNodeConstraintsPolicyResource, rule.Resources.List())
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package podnodeconstraints
+
+import (
+	"io"
+
+	admission "k8s.io/kubernetes/pkg/admission"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	clientset "k8s.io/kubernetes/pkg/client/clientset_generated/internalclientset"
+	kinternalinformers "k8s.io/kubernetes/pkg/client/informers/informers_generated/internalversion"
+	kcorelisters "k8s.io/kubernetes/pkg/client/listers/core/internalversion"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	oadmission "github.com/openshift/origin/pkg/cmd/server/admission"
+	deployapi "github.com/openshift/origin/pkg/deploy/api"
+	"github.com/openshift/origin/pkg/scheduler/admission/podnodeconstraints/api"
+)
+
+// namespaceNodeSelectorAnnotation holds a namespace's default nodeSelector. It is merged over
+// PodNodeConstraintsConfig.DefaultNodeSelector by the PodNodeConstraintsDefaulter plugin.
+const namespaceNodeSelectorAnnotation = "openshift.io/node-selector"
+
+func init() {
+	admission.RegisterPlugin("PodNodeConstraintsDefaulter", func(c clientset.Interface, config io.Reader) (admission.Interface, error) {
+		pluginConfig, err := readConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return NewPodNodeConstraintsDefaulter(pluginConfig), nil
+	})
+}
+
+// NewPodNodeConstraintsDefaulter creates a new admission plugin that defaults the nodeSelector of
+// pod templates which don't specify one, from namespace and cluster-wide defaults. It must run
+// ahead of PodNodeConstraints in the admission chain so any injected selector that lands on the
+// blacklist is still subject to the authorization check on behalf of the requesting user, rather
+// than being attributed to this plugin.
+func NewPodNodeConstraintsDefaulter(config *api.PodNodeConstraintsConfig) admission.Interface {
+	return &podNodeConstraintsDefaulter{
+		config:  config,
+		Handler: admission.NewHandler(admission.Create),
+	}
+}
+
+type podNodeConstraintsDefaulter struct {
+	*admission.Handler
+	config          *api.PodNodeConstraintsConfig
+	namespaceLister kcorelisters.NamespaceLister
+}
+
+var _ = oadmission.WantsInternalKubeInformerFactory(&podNodeConstraintsDefaulter{})
+
+// SetInternalKubeInformerFactory wires in the namespace lister used to resolve the
+// openshift.io/node-selector default nodeSelector annotation.
+func (o *podNodeConstraintsDefaulter) SetInternalKubeInformerFactory(kubeInformers kinternalinformers.SharedInformerFactory) {
+	o.namespaceLister = kubeInformers.Core().InternalVersion().Namespaces().Lister()
+}
+
+func (o *podNodeConstraintsDefaulter) Admit(attr admission.Attributes) error {
+	if o.config == nil || attr.GetSubresource() != "" || attr.GetOperation() != admission.Create {
+		return nil
+	}
+	shouldAdmit, err := shouldAdmitResource(attr.GetResource(), attr.GetKind())
+	if err != nil {
+		return err
+	}
+	if !shouldAdmit {
+		return nil
+	}
+	defaultSelector := o.defaultNodeSelector(attr.GetNamespace())
+	if len(defaultSelector) == 0 {
+		return nil
+	}
+	setNodeSelectorIfUnset(attr.GetObject(), defaultSelector)
+	return nil
+}
+
+// defaultNodeSelector merges the cluster-wide default nodeSelector with the requesting
+// namespace's openshift.io/node-selector annotation, the namespace annotation taking precedence.
+func (o *podNodeConstraintsDefaulter) defaultNodeSelector(namespace string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range o.config.DefaultNodeSelector {
+		merged[k] = v
+	}
+	if o.namespaceLister == nil {
+		return merged
+	}
+	ns, err := o.namespaceLister.Get(namespace)
+	if err != nil {
+		return merged
+	}
+	raw, ok := ns.Annotations[namespaceNodeSelectorAnnotation]
+	if !ok {
+		return merged
+	}
+	parsed, err := labels.ConvertSelectorToLabelsMap(raw)
+	if err != nil {
+		return merged
+	}
+	for k, v := range parsed {
+		merged[k] = v
+	}
+	return merged
+}
+
+// setNodeSelectorIfUnset injects selector into obj's pod template when it doesn't already have a
+// nodeSelector of its own. Its cases must stay in lockstep with getPodSpecForObject's, since
+// shouldAdmitResource (shared with podNodeConstraints) is what decides which kinds reach here.
+func setNodeSelectorIfUnset(obj runtime.Object, selector map[string]string) {
+	var ps *kapi.PodSpec
+	switch r := obj.(type) {
+	case *kapi.Pod:
+		ps = &r.Spec
+	case *kapi.ReplicationController:
+		ps = &r.Spec.Template.Spec
+	case *extensions.Deployment:
+		ps = &r.Spec.Template.Spec
+	case *extensions.ReplicaSet:
+		ps = &r.Spec.Template.Spec
+	case *extensions.Job:
+		ps = &r.Spec.Template.Spec
+	case *extensions.DaemonSet:
+		ps = &r.Spec.Template.Spec
+	case *apps.StatefulSet:
+		ps = &r.Spec.Template.Spec
+	case *batch.CronJob:
+		ps = &r.Spec.JobTemplate.Spec.Template.Spec
+	case *deployapi.DeploymentConfig:
+		ps = &r.Spec.Template.Spec
+	default:
+		return
+	}
+	if len(ps.NodeSelector) > 0 {
+		return
+	}
+	ps.NodeSelector = selector
+}
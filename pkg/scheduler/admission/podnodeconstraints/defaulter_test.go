@@ -0,0 +1,127 @@
+package podnodeconstraints
+
+import (
+	"fmt"
+	"testing"
+
+	admission "k8s.io/kubernetes/pkg/admission"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/serviceaccount"
+
+	oadmission "github.com/openshift/origin/pkg/cmd/server/admission"
+	"github.com/openshift/origin/pkg/scheduler/admission/podnodeconstraints/api"
+)
+
+func defaulterConfig() *api.PodNodeConstraintsConfig {
+	return &api.PodNodeConstraintsConfig{
+		NodeSelectorLabelBlacklist: []string{"bogus"},
+		DefaultNodeSelector:        map[string]string{"bogus": "frank"},
+	}
+}
+
+func TestPodNodeConstraintsDefaulterDefaultsOnlyOnCreate(t *testing.T) {
+	ns := kapi.NamespaceDefault
+	pod := defaultPod()
+	plugin := NewPodNodeConstraintsDefaulter(defaulterConfig())
+	attrs := admission.NewAttributesRecord(pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Update, serviceaccount.UserInfo("", "", ""))
+	if err := plugin.Admit(attrs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pod.Spec.NodeSelector) != 0 {
+		t.Fatalf("expected nodeSelector to be left unset on Update, got: %v", pod.Spec.NodeSelector)
+	}
+
+	attrs = admission.NewAttributesRecord(pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, serviceaccount.UserInfo("", "", ""))
+	if err := plugin.Admit(attrs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.Spec.NodeSelector["bogus"] != "frank" {
+		t.Fatalf("expected default nodeSelector to be injected on Create, got: %v", pod.Spec.NodeSelector)
+	}
+}
+
+func TestPodNodeConstraintsDefaulterDoesNotClobberUserSelector(t *testing.T) {
+	ns := kapi.NamespaceDefault
+	pod := nodeSelectorPod()
+	original := pod.Spec.NodeSelector["bogus"]
+	plugin := NewPodNodeConstraintsDefaulter(defaulterConfig())
+	attrs := admission.NewAttributesRecord(pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, serviceaccount.UserInfo("", "", ""))
+	if err := plugin.Admit(attrs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.Spec.NodeSelector["bogus"] != original {
+		t.Fatalf("expected user-supplied nodeSelector to be preserved, got: %v", pod.Spec.NodeSelector)
+	}
+}
+
+// TestPodNodeConstraintsDefaulterControllerResourceKinds confirms the defaulter injects the
+// default nodeSelector for the newer controller kinds PodNodeConstraints also admits, not just
+// Pod/RC/Deployment/RS/Job/DC.
+func TestPodNodeConstraintsDefaulterControllerResourceKinds(t *testing.T) {
+	ns := kapi.NamespaceDefault
+	tests := []struct {
+		name     string
+		resource unversioned.GroupResource
+		kind     unversioned.GroupKind
+		object   runtime.Object
+		selector func(runtime.Object) map[string]string
+	}{
+		{"DaemonSet", extensions.Resource("daemonsets"), extensions.Kind("DaemonSet"), daemonSetWithNodeSelector(nil), func(obj runtime.Object) map[string]string {
+			return obj.(*extensions.DaemonSet).Spec.Template.Spec.NodeSelector
+		}},
+		{"StatefulSet", apps.Resource("statefulsets"), apps.Kind("StatefulSet"), statefulSetWithNodeSelector(nil), func(obj runtime.Object) map[string]string {
+			return obj.(*apps.StatefulSet).Spec.Template.Spec.NodeSelector
+		}},
+		{"CronJob", batch.Resource("cronjobs"), batch.Kind("CronJob"), cronJobWithNodeSelector(nil), func(obj runtime.Object) map[string]string {
+			return obj.(*batch.CronJob).Spec.JobTemplate.Spec.Template.Spec.NodeSelector
+		}},
+	}
+	for _, tc := range tests {
+		plugin := NewPodNodeConstraintsDefaulter(defaulterConfig())
+		attrs := admission.NewAttributesRecord(tc.object, tc.kind, ns, "test", tc.resource, "", admission.Create, serviceaccount.UserInfo("", "", ""))
+		if err := plugin.Admit(attrs); err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got := tc.selector(tc.object); got["bogus"] != "frank" {
+			t.Errorf("%s: expected default nodeSelector to be injected, got: %v", tc.name, got)
+		}
+	}
+}
+
+// TestPodNodeConstraintsDefaulterThenValidator confirms that a selector injected by the defaulter
+// is still subject to the existing blacklist/authorization check, attributed to the requesting
+// user rather than the controller performing the admission chain.
+func TestPodNodeConstraintsDefaulterThenValidator(t *testing.T) {
+	ns := kapi.NamespaceDefault
+	pod := defaultPod()
+	userinfo := serviceaccount.UserInfo("", "", "")
+
+	defaulter := NewPodNodeConstraintsDefaulter(defaulterConfig())
+	attrs := admission.NewAttributesRecord(pod, kapi.Kind("Pod"), ns, "test", kapi.Resource("pods"), "", admission.Create, userinfo)
+	if err := defaulter.Admit(attrs); err != nil {
+		t.Fatalf("unexpected error from defaulter: %v", err)
+	}
+
+	osClient := fakeClient("pods/binding", reviewResponse(false, ""))
+	validator := NewPodNodeConstraints(defaulterConfig())
+	validator.(oadmission.WantsOpenshiftClient).SetOpenshiftClient(osClient)
+	err := validator.Admit(attrs)
+	expectedError := admission.NewForbidden(attrs, fmt.Errorf("node selection by label(s) [bogus] is prohibited by policy for your role"))
+	if err == nil || err.Error() != expectedError.Error() {
+		t.Fatalf("expected error %q, got: %v", expectedError.Error(), err)
+	}
+
+	// Same injected selector, but this time the requesting user has "pods/bind" access.
+	osClient = fakeClient("pods/binding", reviewResponse(true, ""))
+	validator = NewPodNodeConstraints(defaulterConfig())
+	validator.(oadmission.WantsOpenshiftClient).SetOpenshiftClient(osClient)
+	if err := validator.Admit(attrs); err != nil {
+		t.Fatalf("expected no error for an authorized user, got: %v", err)
+	}
+}
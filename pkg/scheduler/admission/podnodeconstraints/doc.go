@@ -1,9 +1,10 @@
 /*
 Package podnodeconstraints contains the PodNodeConstraints admission
 control plugin. This plugin allows administrators to prohibit the use
-of the NodeName and NodeSelector attributes in pod specs. This enables
-policy to prevent pod requests from influencing which nodes new pods
-are scheduled on.
+of the NodeName and NodeSelector attributes in pod specs, as well as
+the node-targeting NodeAffinity, PodAffinity, and PodAntiAffinity terms
+of a pod's affinity. This enables policy to prevent pod requests from
+influencing which nodes new pods are scheduled on.
 
 Configuration
 
@@ -0,0 +1,131 @@
+package podnodeconstraints
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+
+	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+)
+
+const (
+	defaultSARCacheSize        = 1024
+	defaultSARCacheTTL         = 30 * time.Second
+	defaultSARCacheNegativeTTL = 5 * time.Second
+)
+
+var (
+	sarCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "podnodeconstraints_sar_cache_hits",
+		Help: "Number of PodNodeConstraints SubjectAccessReview cache hits.",
+	})
+	sarCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "podnodeconstraints_sar_cache_misses",
+		Help: "Number of PodNodeConstraints SubjectAccessReview cache misses.",
+	})
+	sarCacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "podnodeconstraints_sar_cache_evictions",
+		Help: "Number of PodNodeConstraints SubjectAccessReview cache entries evicted for capacity or removed on expiry.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(sarCacheHits, sarCacheMisses, sarCacheEvictions)
+}
+
+// sarCache memoizes SubjectAccessReviewResponses by (namespace, user, groups, verb, resource) so
+// that bursts of admissions from the same identity, such as a controller fanning out many pods,
+// don't each issue their own SubjectAccessReview. Positive and negative responses are cached with
+// independently configurable TTLs, and concurrent lookups for the same key share a single
+// in-flight SubjectAccessReview rather than each issuing their own.
+type sarCache struct {
+	cache       *lru.Cache
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]*sarCacheCall
+}
+
+type sarCacheEntry struct {
+	response *authorizationapi.SubjectAccessReviewResponse
+	expires  time.Time
+}
+
+type sarCacheCall struct {
+	done     chan struct{}
+	response *authorizationapi.SubjectAccessReviewResponse
+	err      error
+}
+
+func newSARCache(size int, ttl, negativeTTL time.Duration) *sarCache {
+	c, _ := lru.NewWithEvict(size, func(key interface{}, value interface{}) {
+		sarCacheEvictions.Inc()
+	})
+	return &sarCache{
+		cache:       c,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		inflight:    map[string]*sarCacheCall{},
+	}
+}
+
+// sarCacheKey builds the cache key for a SubjectAccessReview over (namespace, user, groups, verb,
+// resource). It deliberately omits the reviewed object's name, trading per-object precision for
+// the ability to share one cached verdict across a burst of pods from the same identity.
+//
+// Any SubjectAccessReview cached under this key MUST NOT itself be scoped to a resource name: a
+// response to a name-scoped review (e.g. ResourceName set on the request) is only valid for that
+// one name, and caching it under a key that doesn't include the name would let it be served back
+// as the verdict for every other differently-named object from the same user/namespace/groups.
+// Callers that need a resourceName-scoped check must not go through this cache.
+func sarCacheKey(namespace, user string, groups []string, verb, resource string) string {
+	sortedGroups := append([]string(nil), groups...)
+	sort.Strings(sortedGroups)
+	return strings.Join([]string{namespace, user, strings.Join(sortedGroups, ","), verb, resource}, "\x00")
+}
+
+// getOrCreate returns the cached response for key if present and unexpired, otherwise calls fetch
+// to populate the cache. Concurrent calls for the same key block on, and share the result of, a
+// single in-flight fetch.
+func (c *sarCache) getOrCreate(key string, fetch func() (*authorizationapi.SubjectAccessReviewResponse, error)) (*authorizationapi.SubjectAccessReviewResponse, error) {
+	if entry, ok := c.cache.Get(key); ok {
+		cached := entry.(*sarCacheEntry)
+		if time.Now().Before(cached.expires) {
+			sarCacheHits.Inc()
+			return cached.response, nil
+		}
+		c.cache.Remove(key)
+	}
+
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.response, call.err
+	}
+	call := &sarCacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	sarCacheMisses.Inc()
+	call.response, call.err = fetch()
+	if call.err == nil {
+		ttl := c.ttl
+		if call.response == nil || !call.response.Allowed {
+			ttl = c.negativeTTL
+		}
+		c.cache.Add(key, &sarCacheEntry{response: call.response, expires: time.Now().Add(ttl)})
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.response, call.err
+}
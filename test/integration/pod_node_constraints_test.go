@@ -176,6 +176,318 @@ func TestPodNodeConstraintsAdmissionPluginWithDaemonSetProhibitNodeTargeting(t *
 	}
 }
 
+func testPodNodeConstraintsPodWithAntiAffinityTopologyKey(topologyKey string) *kapi.Pod {
+	pod := testPodNodeConstraintsPod("", &map[string]string{})
+	pod.Spec.Affinity = &kapi.Affinity{
+		PodAntiAffinity: &kapi.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []kapi.PodAffinityTerm{
+				{TopologyKey: topologyKey},
+			},
+		},
+	}
+	return pod
+}
+
+func TestPodNodeConstraintsAdmissionPluginAllowedAffinityTopologyKey(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		AllowedAffinityTopologyKeys: []string{"topology.kubernetes.io/zone"},
+	}
+	ns := "test-project"
+	_, kclient := setupUserPodNodeConstraintsTest(t, config, ns, "derples")
+	_, err := kclient.Pods(ns).Create(testPodNodeConstraintsPodWithAntiAffinityTopologyKey("topology.kubernetes.io/zone"))
+	checkErr(t, err)
+}
+
+func TestPodNodeConstraintsAdmissionPluginDisallowedAffinityTopologyKey(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		AllowedAffinityTopologyKeys: []string{"topology.kubernetes.io/zone"},
+	}
+	ns := "test-project"
+	_, kclient := setupUserPodNodeConstraintsTest(t, config, ns, "derples")
+	expectedError := testPodNodeConstraintsExpectedError(`pod anti-affinity topology key "kubernetes.io/hostname" is not in the allowed list for your role`)
+	_, err := kclient.Pods(ns).Create(testPodNodeConstraintsPodWithAntiAffinityTopologyKey("kubernetes.io/hostname"))
+	if err == nil {
+		t.Fatalf("Expected error %q, no error received", expectedError.Error())
+	}
+	if err.Error() != expectedError.Error() {
+		t.Errorf("expected error %q, got: %q", expectedError.Error(), err.Error())
+	}
+}
+
+func TestPodNodeConstraintsAdmissionPluginDeniedNodeSelectorLabel(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		DeniedNodeSelectorLabels: []string{"foo"},
+	}
+	ns := "test-project"
+	_, kclient := setupUserPodNodeConstraintsTest(t, config, ns, "derples")
+	nodeSelector := &map[string]string{"foo": "bar"}
+	expectedError := testPodNodeConstraintsExpectedError(`node selector label "foo" is prohibited by policy`)
+	_, err := kclient.Pods(ns).Create(testPodNodeConstraintsPod("", nodeSelector))
+	if err == nil {
+		t.Fatalf("Expected error %q, no error received", expectedError.Error())
+	}
+	if err.Error() != expectedError.Error() {
+		t.Errorf("expected error %q, got: %q", expectedError.Error(), err.Error())
+	}
+}
+
+func TestPodNodeConstraintsAdmissionPluginDeniedNodeSelectorLabelWithDaemonSet(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		DeniedNodeSelectorLabels: []string{"foo"},
+	}
+	ns := kapi.NamespaceDefault
+	kclient := setupClusterAdminPodNodeConstraintsTest(t, config)
+
+	node := &kapi.Node{}
+	node.Labels = map[string]string{"foo": "bar"}
+	node.Name = "mynode"
+	node.Status = kapi.NodeStatus{
+		Conditions: []kapi.NodeCondition{
+			{
+				Type:   kapi.NodeReady,
+				Status: kapi.ConditionTrue,
+			},
+		},
+	}
+	_, err := kclient.Nodes().Create(node)
+	checkErr(t, err)
+
+	dsTemplate := newValidDaemonSet()
+	dsTemplate.Spec.Template.Spec.NodeSelector = map[string]string{"foo": "bar"}
+
+	_, err = kclient.Extensions().DaemonSets(ns).Create(dsTemplate)
+	checkErr(t, err)
+
+	podWatch, err := kclient.Pods(ns).Watch(kapi.ListOptions{FieldSelector: fields.Everything(), ResourceVersion: "0"})
+	checkErr(t, err)
+	defer podWatch.Stop()
+	for {
+		select {
+		case e := <-podWatch.ResultChan():
+			if e.Type == watchapi.Added {
+				pod, ok := e.Object.(*kapi.Pod)
+				if !ok {
+					continue
+				}
+				t.Fatalf("expected no pod to be created for a DaemonSet using a denied node selector label, got: %#v", pod)
+			}
+		case <-time.After(10 * time.Second):
+			// DaemonSet controller never succeeded in creating the pod, as expected.
+			return
+		}
+	}
+}
+
+func TestPodNodeConstraintsAdmissionPluginAllowedNodeSelectorLabelWithDaemonSet(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		AllowedNodeSelectorLabels: []string{"a"},
+	}
+	ns := kapi.NamespaceDefault
+	kclient := setupClusterAdminPodNodeConstraintsTest(t, config)
+
+	node := &kapi.Node{}
+	node.Labels = map[string]string{"a": "b"}
+	node.Name = "mynode"
+	node.Status = kapi.NodeStatus{
+		Conditions: []kapi.NodeCondition{
+			{
+				Type:   kapi.NodeReady,
+				Status: kapi.ConditionTrue,
+			},
+		},
+	}
+	_, err := kclient.Nodes().Create(node)
+	checkErr(t, err)
+
+	dsTemplate := newValidDaemonSet()
+	dsTemplate.Spec.Template.Spec.NodeSelector = map[string]string{"a": "b"}
+
+	_, err = kclient.Extensions().DaemonSets(ns).Create(dsTemplate)
+	checkErr(t, err)
+
+	podWatch, err := kclient.Pods(ns).Watch(kapi.ListOptions{FieldSelector: fields.Everything(), ResourceVersion: "0"})
+	checkErr(t, err)
+	defer podWatch.Stop()
+	for {
+		select {
+		case e := <-podWatch.ResultChan():
+			if e.Type == watchapi.Added {
+				pod, ok := e.Object.(*kapi.Pod)
+				if !ok {
+					continue
+				}
+				if pod.Labels["a"] == "b" {
+					return
+				}
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out")
+		}
+	}
+}
+
+func testPodNodeConstraintsPodWithToleration(toleration kapi.Toleration) *kapi.Pod {
+	pod := testPodNodeConstraintsPod("", &map[string]string{})
+	pod.Spec.Tolerations = []kapi.Toleration{toleration}
+	return pod
+}
+
+var masterToleration = kapi.Toleration{Key: "node-role.kubernetes.io/master", Effect: kapi.TaintEffectNoSchedule}
+
+func TestPodNodeConstraintsAdmissionPluginDisallowedTolerationForProjectUser(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		TolerationConstraints: pluginapi.TolerationConstraints{
+			AllowedTolerations: []kapi.Toleration{{Key: "dedicated", Effect: kapi.TaintEffectNoSchedule}},
+		},
+	}
+	ns := "test-project"
+	_, kclient := setupUserPodNodeConstraintsTest(t, config, ns, "derples")
+	expectedError := testPodNodeConstraintsExpectedError("node selection by toleration(s) [node-role.kubernetes.io/master:NoSchedule] is prohibited by policy for your role")
+	_, err := kclient.Pods(ns).Create(testPodNodeConstraintsPodWithToleration(masterToleration))
+	if err == nil {
+		t.Fatalf("Expected error %q, no error received", expectedError.Error())
+	}
+	if err.Error() != expectedError.Error() {
+		t.Errorf("expected error %q, got: %q", expectedError.Error(), err.Error())
+	}
+}
+
+func TestPodNodeConstraintsAdmissionPluginAllowedTolerationForClusterAdmin(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		TolerationConstraints: pluginapi.TolerationConstraints{
+			AllowedTolerations: []kapi.Toleration{{Key: "dedicated", Effect: kapi.TaintEffectNoSchedule}},
+		},
+	}
+	kclient := setupClusterAdminPodNodeConstraintsTest(t, config)
+	_, err := kclient.Pods(testutil.Namespace()).Create(testPodNodeConstraintsPodWithToleration(masterToleration))
+	if err != nil {
+		t.Fatalf("Unexpected: %v", err)
+	}
+}
+
+func TestPodNodeConstraintsAdmissionPluginDisallowedTolerationWithDaemonSet(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		TolerationConstraints: pluginapi.TolerationConstraints{
+			AllowedTolerations: []kapi.Toleration{{Key: "dedicated", Effect: kapi.TaintEffectNoSchedule}},
+		},
+	}
+	ns := kapi.NamespaceDefault
+	kclient := setupClusterAdminPodNodeConstraintsTest(t, config)
+
+	node := &kapi.Node{}
+	node.Labels = map[string]string{"foo": "bar"}
+	node.Name = "mynode"
+	node.Status = kapi.NodeStatus{
+		Conditions: []kapi.NodeCondition{
+			{
+				Type:   kapi.NodeReady,
+				Status: kapi.ConditionTrue,
+			},
+		},
+	}
+	_, err := kclient.Nodes().Create(node)
+	checkErr(t, err)
+
+	dsTemplate := newValidDaemonSet()
+	dsTemplate.Spec.Template.Spec.Tolerations = []kapi.Toleration{masterToleration}
+
+	_, err = kclient.Extensions().DaemonSets(ns).Create(dsTemplate)
+	checkErr(t, err)
+
+	podWatch, err := kclient.Pods(ns).Watch(kapi.ListOptions{FieldSelector: fields.Everything(), ResourceVersion: "0"})
+	checkErr(t, err)
+	defer podWatch.Stop()
+	for {
+		select {
+		case e := <-podWatch.ResultChan():
+			if e.Type == watchapi.Added {
+				pod, ok := e.Object.(*kapi.Pod)
+				if !ok {
+					continue
+				}
+				if pod.Labels["a"] == "b" {
+					return
+				}
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out")
+		}
+	}
+}
+
+// TestPodNodeConstraintsAdmissionPluginNamespaceProfile confirms that a namespace opting into a
+// named, more permissive PodNodeConstraintsConfig.Profiles entry via the
+// pod-node-constraints.openshift.io/config annotation can node-target even though the cluster
+// default prohibits it.
+//
+// PodNodeConstraintsProfileResolver's highest-precedence, per-ServiceAccount override is covered
+// end-to-end by TestPodNodeConstraintsAdmissionPluginServiceAccountProfile below, using the
+// default serviceAccountAnnotationProfileResolver. This snapshot doesn't carry the
+// SecurityContextConstraints admission subsystem, so a resolver that derives the override from
+// the SCCs bound to the ServiceAccount instead of its annotation can't be exercised end-to-end
+// here; that variant is unit-tested instead via a fake PodNodeConstraintsProfileResolver in
+// admission_test.go.
+func TestPodNodeConstraintsAdmissionPluginNamespaceProfile(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		Profiles: map[string]pluginapi.PodNodeConstraintsProfile{
+			"permissive": {AllowNodeName: true},
+		},
+	}
+	ns := "test-project"
+	_, kclient := setupUserPodNodeConstraintsTest(t, config, ns, "derples")
+
+	nsObj, err := kclient.Namespaces().Get(ns)
+	checkErr(t, err)
+	if nsObj.Annotations == nil {
+		nsObj.Annotations = map[string]string{}
+	}
+	nsObj.Annotations["pod-node-constraints.openshift.io/config"] = "permissive"
+	_, err = kclient.Namespaces().Update(nsObj)
+	checkErr(t, err)
+
+	_, err = kclient.Pods(ns).Create(testPodNodeConstraintsPod("nodename.example.com", &map[string]string{}))
+	if err != nil {
+		t.Fatalf("Unexpected: %v", err)
+	}
+}
+
+// TestPodNodeConstraintsAdmissionPluginServiceAccountProfile confirms that the default
+// PodNodeConstraintsProfileResolver resolves a more permissive profile from the
+// pod-node-constraints.openshift.io/config annotation on the pod's ServiceAccount, and that it
+// takes precedence over a stricter profile selected by the namespace annotation.
+func TestPodNodeConstraintsAdmissionPluginServiceAccountProfile(t *testing.T) {
+	config := &pluginapi.PodNodeConstraintsConfig{
+		Profiles: map[string]pluginapi.PodNodeConstraintsProfile{
+			"permissive": {AllowNodeName: true},
+			"strict":     {},
+		},
+	}
+	ns := "test-project"
+	_, kclient := setupUserPodNodeConstraintsTest(t, config, ns, "derples")
+
+	nsObj, err := kclient.Namespaces().Get(ns)
+	checkErr(t, err)
+	if nsObj.Annotations == nil {
+		nsObj.Annotations = map[string]string{}
+	}
+	nsObj.Annotations["pod-node-constraints.openshift.io/config"] = "strict"
+	_, err = kclient.Namespaces().Update(nsObj)
+	checkErr(t, err)
+
+	sa := &kapi.ServiceAccount{}
+	sa.Name = "permissive-sa"
+	sa.Annotations = map[string]string{"pod-node-constraints.openshift.io/config": "permissive"}
+	_, err = kclient.ServiceAccounts(ns).Create(sa)
+	checkErr(t, err)
+
+	pod := testPodNodeConstraintsPod("nodename.example.com", &map[string]string{})
+	pod.Spec.ServiceAccountName = sa.Name
+	_, err = kclient.Pods(ns).Create(pod)
+	if err != nil {
+		t.Fatalf("Unexpected: %v", err)
+	}
+}
+
 func newValidDaemonSet() *extensions.DaemonSet {
 	return &extensions.DaemonSet{
 		ObjectMeta: kapi.ObjectMeta{